@@ -0,0 +1,153 @@
+// Package cache stores the outputs of tasks, keyed by the fingerprints of
+// their inputs, so that a [runner.Run] can restore a task's outputs rather
+// than running it again.
+//
+// A [Cache] is a simple blob store. [Dir] implements it with a local
+// directory; other implementations could share outputs between machines.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by [Cache.Get] when there's no entry for a key.
+var ErrNotFound = errors.New("not found in cache")
+
+// A Cache stores blobs by key. Keys are hex-encoded hashes, so an entry's
+// content never changes once it's written.
+//
+// A Cache must be safe to use concurrently from multiple goroutines.
+type Cache interface {
+	// Get returns a reader for the entry with the given key, or
+	// ErrNotFound if there isn't one. The caller must close the reader.
+	Get(key string) (io.ReadCloser, error)
+
+	// Put stores the contents of r as the entry with the given key,
+	// replacing any existing entry.
+	Put(key string, r io.Reader) error
+}
+
+// Dir is a [Cache] that stores each entry as a file in a local directory.
+type Dir struct {
+	path string
+}
+
+// *Dir implements Cache
+var _ Cache = &Dir{}
+
+// NewDir returns a Dir that stores entries beneath path, creating it as
+// needed.
+func NewDir(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// Get implements [Cache]. It marks the entry as recently used, so that
+// [Dir.Prune] keeps it.
+func (d *Dir) Get(key string) (io.ReadCloser, error) {
+	p, err := d.entryPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return f, nil
+}
+
+// Put implements [Cache]. Entries are written to a temporary file and then
+// renamed into place, so that a reader never sees a partial entry.
+func (d *Dir) Put(key string, r io.Reader) error {
+	p, err := d.entryPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Prune removes the least recently used entries until the entries' total
+// size is at most maxSize bytes. It returns the number of entries removed.
+func (d *Dir) Prune(maxSize int64) (int, error) {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	err := filepath.WalkDir(d.path, func(p string, de fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{p, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	removed := 0
+	for _, e := range entries {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			return removed, err
+		}
+		total -= e.size
+		removed++
+	}
+	return removed, nil
+}
+
+// entryPath returns the path of the file for the entry with the given key.
+// Entries are spread across subdirectories by their keys' first two
+// characters.
+func (d *Dir) entryPath(key string) (string, error) {
+	if len(key) < 3 || strings.ContainsFunc(key, func(r rune) bool {
+		return !('0' <= r && r <= '9' || 'a' <= r && r <= 'f')
+	}) {
+		return "", fmt.Errorf("invalid cache key '%s'", key)
+	}
+	return filepath.Join(d.path, key[:2], key), nil
+}
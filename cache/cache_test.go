@@ -0,0 +1,70 @@
+package cache_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"monks.co/run/cache"
+)
+
+func get(t *testing.T, c cache.Cache, key string) string {
+	t.Helper()
+	r, err := c.Get(key)
+	assert.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func TestDir(t *testing.T) {
+	c := cache.NewDir(filepath.Join(t.TempDir(), "cache"))
+
+	_, err := c.Get("abc123")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.NoError(t, c.Put("abc123", strings.NewReader("outputs")))
+	assert.Equal(t, "outputs", get(t, c, "abc123"))
+
+	assert.NoError(t, c.Put("abc123", strings.NewReader("replaced")))
+	assert.Equal(t, "replaced", get(t, c, "abc123"))
+
+	assert.Error(t, c.Put("../escape", strings.NewReader("")))
+	_, err = c.Get("")
+	assert.Error(t, err)
+}
+
+func TestDirPrune(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.NewDir(dir)
+
+	// Entries are used in the order aaa, bbb, ccc, and then aaa again.
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"aaa", "bbb", "ccc"} {
+		assert.NoError(t, c.Put(key, strings.NewReader(strings.Repeat("x", 10))))
+		when := base.Add(time.Duration(i) * time.Minute)
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, key[:2], key), when, when))
+	}
+	get(t, c, "aaa")
+
+	removed, err := c.Prune(25)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, err = c.Get("bbb")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+	assert.Equal(t, strings.Repeat("x", 10), get(t, c, "ccc"))
+
+	removed, err = c.Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	// Pruning a cache that was never written to is fine.
+	removed, err = cache.NewDir(filepath.Join(dir, "missing")).Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
@@ -4,7 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/muesli/reflow/indent"
 	"github.com/muesli/reflow/wordwrap"
@@ -52,6 +55,14 @@ func registerFlags(modes []mode) {
 				flag.StringVar(fv.Addr().Interface().(*string), flagName, defValue, usage)
 			case reflect.Bool:
 				flag.BoolVar(fv.Addr().Interface().(*bool), flagName, false, usage)
+			case reflect.Int:
+				def, _ := strconv.Atoi(defValue)
+				flag.IntVar(fv.Addr().Interface().(*int), flagName, def, usage)
+			case reflect.Int64:
+				if field.Type == reflect.TypeFor[time.Duration]() {
+					def, _ := time.ParseDuration(defValue)
+					flag.DurationVar(fv.Addr().Interface().(*time.Duration), flagName, def, usage)
+				}
 			case reflect.Slice:
 				if field.Type.Elem().Kind() == reflect.String {
 					ptr := fv.Addr().Interface().(*[]string)
@@ -107,6 +118,14 @@ func syncSharedFlags(modes []mode) {
 				fv.SetString(val)
 			case reflect.Bool:
 				fv.SetBool(val == "true")
+			case reflect.Int:
+				if n, err := strconv.Atoi(val); err == nil {
+					fv.SetInt(int64(n))
+				}
+			case reflect.Int64:
+				if d, err := time.ParseDuration(val); err == nil {
+					fv.SetInt(int64(d))
+				}
 			}
 		}
 	}
@@ -306,7 +325,9 @@ func isZeroValue(f *flag.Flag, value string) bool {
 	return value == z.Interface().(flag.Value).String()
 }
 
-// setPositional sets the positional arg value on the inv struct.
+// setPositional sets the positional arg value on the inv struct. A []string
+// positional field gets every positional arg up to any "--", and a []string
+// field tagged `pos:"--"` gets the arguments after it.
 func setPositional(m *mode) {
 	posArg := flag.Arg(0)
 	if m.inv == nil || posArg == "" {
@@ -319,9 +340,22 @@ func setPositional(m *mode) {
 	rt := rv.Type()
 	for j := 0; j < rt.NumField(); j++ {
 		field := rt.Field(j)
-		if field.Tag.Get("pos") != "" {
-			rv.Field(j).SetString(posArg)
-			return
+		switch field.Tag.Get("pos") {
+		case "":
+		case "--":
+			if i := slices.Index(flag.Args(), "--"); i >= 0 {
+				rv.Field(j).Set(reflect.ValueOf(flag.Args()[i+1:]))
+			}
+		default:
+			if field.Type.Kind() == reflect.Slice {
+				args := flag.Args()
+				if i := slices.Index(args, "--"); i >= 0 {
+					args = args[:i]
+				}
+				rv.Field(j).Set(reflect.ValueOf(args))
+			} else {
+				rv.Field(j).SetString(posArg)
+			}
 		}
 	}
 }
@@ -0,0 +1,293 @@
+// Package dotenv parses .env files: lines of KEY=VALUE assignments, as used
+// by many tools to configure a process's environment.
+//
+// The format supported is,
+//
+//	# Comments start with a '#'.
+//	PLAIN=value with spaces # trailing comments are ignored
+//	export EXPORTED=value
+//	SINGLE='literal: no $EXPANSION or \escapes'
+//	DOUBLE="escapes like \n and \" and expansion like ${PLAIN} and $PLAIN"
+//	MULTILINE="first line
+//	second line"
+//
+// Unquoted and double-quoted values can refer to variables assigned earlier,
+// in the same file or an earlier one, or in the base environment. References
+// to undefined variables expand to the empty string.
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Load reads the files at paths in order, returning their assignments as
+// "KEY=VALUE" strings, in order. Values can refer to variables from env,
+// which is a list of "KEY=VALUE" strings like [os.Environ] returns, as well as
+// to variables assigned by earlier files. Files that don't exist are skipped.
+func Load(env []string, paths ...string) ([]string, error) {
+	vars := map[string]string{}
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			vars[k] = v
+		}
+	}
+
+	var out []string
+	for _, path := range paths {
+		bs, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		assignments, err := parse(string(bs), vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		out = append(out, assignments...)
+	}
+	return out, nil
+}
+
+// Parse parses the contents of a .env file, returning its assignments as
+// "KEY=VALUE" strings, in order. Values can refer to variables from env,
+// which is a list of "KEY=VALUE" strings like [os.Environ] returns.
+func Parse(src string, env []string) ([]string, error) {
+	vars := map[string]string{}
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			vars[k] = v
+		}
+	}
+	return parse(src, vars)
+}
+
+// parse parses src, recording each assignment in vars as it goes so that
+// later values can refer to it.
+func parse(src string, vars map[string]string) ([]string, error) {
+	p := &parser{src: src, line: 1}
+	var out []string
+	for {
+		p.skipBlank()
+		if p.done() {
+			return out, nil
+		}
+		line := p.line
+		key, value, err := p.assignment(vars)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		vars[key] = value
+		out = append(out, key+"="+value)
+	}
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() byte { return p.src[p.pos] }
+
+func (p *parser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipBlank skips whitespace, blank lines, and comment lines.
+func (p *parser) skipBlank() {
+	for !p.done() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipSpace skips spaces and tabs, but not newlines.
+func (p *parser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+// skipLine skips the rest of the current line, including its newline.
+func (p *parser) skipLine() {
+	for !p.done() && p.next() != '\n' {
+	}
+}
+
+// endLine expects the rest of the current line to be empty or a comment.
+func (p *parser) endLine() error {
+	p.skipSpace()
+	if p.done() {
+		return nil
+	}
+	switch p.peek() {
+	case '\r', '\n', '#':
+		p.skipLine()
+		return nil
+	}
+	return fmt.Errorf("unexpected %q after closing quote", p.peek())
+}
+
+func (p *parser) assignment(vars map[string]string) (string, string, error) {
+	key := p.name(true)
+	if key == "export" && !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpace()
+		key = p.name(true)
+	}
+	if key == "" {
+		return "", "", fmt.Errorf("expected a variable name, found %q", p.peek())
+	}
+	p.skipSpace()
+	if p.done() || p.peek() != '=' {
+		return "", "", fmt.Errorf("expected '=' after %s", key)
+	}
+	p.next()
+	p.skipSpace()
+
+	if p.done() {
+		return key, "", nil
+	}
+	switch p.peek() {
+	case '\'':
+		value, err := p.singleQuoted()
+		if err != nil {
+			return "", "", err
+		}
+		return key, value, p.endLine()
+	case '"':
+		value, err := p.doubleQuoted(vars)
+		if err != nil {
+			return "", "", err
+		}
+		return key, value, p.endLine()
+	default:
+		return key, p.unquoted(vars), nil
+	}
+}
+
+// name reads a variable name. Names being assigned can contain dots, but
+// names in references can't, so that "$NAME.txt" refers to NAME.
+func (p *parser) name(dots bool) string {
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case p.pos > start && '0' <= c && c <= '9':
+		case p.pos > start && dots && c == '.':
+		default:
+			return p.src[start:p.pos]
+		}
+		p.next()
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) singleQuoted() (string, error) {
+	p.next()
+	start := p.pos
+	for !p.done() {
+		if p.peek() == '\'' {
+			value := p.src[start:p.pos]
+			p.next()
+			return value, nil
+		}
+		p.next()
+	}
+	return "", errors.New("unterminated single-quoted value")
+}
+
+func (p *parser) doubleQuoted(vars map[string]string) (string, error) {
+	p.next()
+	var b strings.Builder
+	for !p.done() {
+		c := p.next()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.done() {
+				continue
+			}
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		case '$':
+			b.WriteString(p.expansion(vars))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated double-quoted value")
+}
+
+// unquoted reads a value up to the end of the line or a comment, which must
+// be preceded by whitespace.
+func (p *parser) unquoted(vars map[string]string) string {
+	var b strings.Builder
+	for !p.done() {
+		c := p.peek()
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '#' && (b.Len() == 0 || strings.HasSuffix(b.String(), " ") || strings.HasSuffix(b.String(), "\t")) {
+			break
+		}
+		p.next()
+		if c == '$' {
+			b.WriteString(p.expansion(vars))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	p.skipLine()
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// expansion reads a variable reference following a '$', like "NAME" or
+// "{NAME}", returning its value. If no reference follows, it returns "$".
+func (p *parser) expansion(vars map[string]string) string {
+	if !p.done() && p.peek() == '{' {
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return "$"
+		}
+		name := p.src[p.pos+1 : p.pos+end]
+		for range end + 1 {
+			p.next()
+		}
+		return vars[name]
+	}
+	name := p.name(false)
+	if name == "" {
+		return "$"
+	}
+	return vars[name]
+}
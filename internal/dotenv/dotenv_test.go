@@ -0,0 +1,69 @@
+package dotenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"monks.co/run/internal/dotenv"
+)
+
+func TestParse(t *testing.T) {
+	src := `
+# A comment.
+PLAIN=value with spaces # a trailing comment
+export EXPORTED=exported
+EMPTY=
+HASH=a#b
+SINGLE='no $PLAIN or \n here' # comment
+DOUBLE="tab\there \"quoted\" \$PLAIN ${PLAIN}"
+MULTILINE="one
+two"
+REF=$HOME/${EXPORTED}.txt $UNDEFINED.
+file.name=dotted
+`
+	env, err := dotenv.Parse(src, []string{"HOME=/home/me"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"PLAIN=value with spaces",
+		"EXPORTED=exported",
+		"EMPTY=",
+		"HASH=a#b",
+		`SINGLE=no $PLAIN or \n here`,
+		"DOUBLE=tab\there \"quoted\" $PLAIN value with spaces",
+		"MULTILINE=one\ntwo",
+		"REF=/home/me/exported.txt .",
+		"file.name=dotted",
+	}, env)
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"A=1\nB":             "line 2: expected '=' after B",
+		"A=1\n=2":            "line 2: expected a variable name, found '='",
+		"A='unterminated":    "line 1: unterminated single-quoted value",
+		"A=\"unterminated":   "line 1: unterminated double-quoted value",
+		"A=\"quoted\" extra": "line 1: unexpected 'e' after closing quote",
+	} {
+		_, err := dotenv.Parse(src, nil)
+		assert.EqualError(t, err, msg, src)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	env := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	assert.NoError(t, os.WriteFile(env, []byte("HOST=localhost\nPORT=8080\n"), 0o644))
+	assert.NoError(t, os.WriteFile(local, []byte("PORT=9090\nURL=http://$HOST:$PORT\n"), 0o644))
+
+	vars, err := dotenv.Load(nil, env, filepath.Join(dir, "missing"), local)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"HOST=localhost",
+		"PORT=8080",
+		"PORT=9090",
+		"URL=http://localhost:9090",
+	}, vars)
+}
@@ -0,0 +1,158 @@
+// Package fingerprint hashes the files that match a set of path patterns, so
+// that a task can be skipped when none of its inputs have changed.
+//
+// Patterns are paths relative to a base directory. They can use "*", which
+// matches within a single path segment, and "**", which matches across any
+// number of segments, as in "src/**/*.go". A pattern without wildcards that
+// names a directory matches every file beneath it.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Files returns the paths, relative to dir and in sorted order, of the files
+// matching any of the patterns.
+func Files(dir string, patterns []string) ([]string, error) {
+	set := map[string]struct{}{}
+	for _, pattern := range patterns {
+		matches, err := match(dir, filepath.ToSlash(filepath.Clean(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			set[m] = struct{}{}
+		}
+	}
+	files := make([]string, 0, len(set))
+	for f := range set {
+		files = append(files, f)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Sum returns a hex-encoded SHA-256 hash of the names and contents of the
+// files matching the patterns, and of the extra strings. It changes whenever
+// a matching file is added, removed, renamed, or edited, or whenever extra
+// does.
+func Sum(dir string, patterns []string, extra ...string) (string, error) {
+	files, err := Files(dir, patterns)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, s := range extra {
+		fmt.Fprintf(h, "%d:%s\n", len(s), s)
+	}
+	for _, f := range files {
+		sum, err := hashFile(filepath.Join(dir, f))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %s\n", sum, f)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// match returns the files under dir matching pattern, which is
+// slash-separated.
+func match(dir, pattern string) ([]string, error) {
+	segments := strings.Split(pattern, "/")
+	i := slices.IndexFunc(segments, func(seg string) bool {
+		return strings.ContainsAny(seg, "*?[")
+	})
+	if i < 0 {
+		return walk(dir, pattern, nil)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	root := strings.Join(segments[:i], "/")
+	if root == "" {
+		root = "."
+	}
+	return walk(dir, root, func(name string) bool {
+		return matchSegments(segments, strings.Split(name, "/"))
+	})
+}
+
+// matchSegments reports whether the path segments in name match those in
+// pattern, where a "**" segment matches any number of segments, including
+// none, and other segments match as in [path.Match].
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// walk returns the files beneath root, relative to dir, that match. If match
+// is nil, it returns all of them. A root that doesn't exist has no files.
+func walk(dir, root string, match func(string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(filepath.Join(dir, root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if match == nil || match(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// File returns a hex-encoded SHA-256 hash of the contents of the file at
+// path.
+func File(path string) (string, error) {
+	return hashFile(path)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+package fingerprint_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"monks.co/run/internal/fingerprint"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":            "",
+		"go.mod":             "",
+		"src/a.go":           "",
+		"src/deep/b.go":      "",
+		"src/deep/b.txt":     "",
+		"assets/logo.svg":    "",
+		"assets/css/app.css": "",
+	})
+
+	for _, tc := range []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"*.go"}, []string{"main.go"}},
+		{[]string{"**/*.go"}, []string{"main.go", "src/a.go", "src/deep/b.go"}},
+		{[]string{"src/*.go"}, []string{"src/a.go"}},
+		{[]string{"src/**"}, []string{"src/a.go", "src/deep/b.go", "src/deep/b.txt"}},
+		{[]string{"assets", "go.mod"}, []string{"assets/css/app.css", "assets/logo.svg", "go.mod"}},
+		{[]string{"./go.mod", "go.mod"}, []string{"go.mod"}},
+		{[]string{"missing", "missing/**/*.go"}, []string{}},
+	} {
+		files, err := fingerprint.Files(dir, tc.patterns)
+		assert.NoError(t, err, tc.patterns)
+		assert.Equal(t, tc.want, files, tc.patterns)
+	}
+}
+
+func TestSum(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/a.go": "package a",
+		"README":   "not a source",
+	})
+	sum := func(extra ...string) string {
+		t.Helper()
+		s, err := fingerprint.Sum(dir, []string{"src/**"}, extra...)
+		assert.NoError(t, err)
+		return s
+	}
+
+	before := sum("go build")
+	assert.Equal(t, before, sum("go build"))
+	assert.NotEqual(t, before, sum("go vet"))
+
+	// Files that don't match don't count.
+	writeFiles(t, dir, map[string]string{"README": "changed"})
+	assert.Equal(t, before, sum("go build"))
+
+	// Edits, additions, and renames do.
+	writeFiles(t, dir, map[string]string{"src/a.go": "package b"})
+	edited := sum("go build")
+	assert.NotEqual(t, before, edited)
+
+	writeFiles(t, dir, map[string]string{"src/b.go": ""})
+	added := sum("go build")
+	assert.NotEqual(t, edited, added)
+
+	assert.NoError(t, os.Rename(filepath.Join(dir, "src/b.go"), filepath.Join(dir, "src/c.go")))
+	assert.NotEqual(t, added, sum("go build"))
+}
@@ -17,6 +17,7 @@ type Task struct {
 	dependencies []string
 	triggers     []string
 	output       string
+	status       string
 
 	// Channels for controlling the task's lifecycle.
 	ready  <-chan struct{} // close to signal readiness
@@ -56,6 +57,12 @@ func (t *Task) WithOutput(output string) *Task {
 	return &cp
 }
 
+func (t *Task) WithStatus(status string) *Task {
+	cp := *t
+	cp.status = status
+	return &cp
+}
+
 // WithReady configures the task to wait for a readiness signal. The caller
 // should close the returned channel to signal readiness.
 func (t *Task) WithReady(ch <-chan struct{}) *Task {
@@ -94,6 +101,7 @@ func (t *Task) Metadata() task.TaskMetadata {
 		Watch:        t.watch,
 		Dependencies: t.dependencies,
 		Triggers:     t.triggers,
+		Status:       t.status,
 	}
 }
 
@@ -0,0 +1,247 @@
+// Package probe checks whether a running task is up, by requesting a URL,
+// connecting to a port, watching for a line of output, or running a command.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"monks.co/run/internal/mutex"
+	"monks.co/run/internal/script"
+	"monks.co/run/task"
+)
+
+// DefaultInterval is the time between attempts for probes that don't specify
+// an Interval.
+const DefaultInterval = time.Second
+
+// DefaultFailureThreshold is the number of consecutive failures after which
+// Monitor gives up, for probes that don't specify a FailureThreshold.
+const DefaultFailureThreshold = 3
+
+// maxPartialLine bounds how much of an unterminated output line a Checker
+// buffers while looking for a log match.
+const maxPartialLine = 64 * 1024
+
+// A Checker evaluates a [task.Probe] against one execution of a task.
+//
+// For log probes, the task's output must be written to the Checker. A log
+// probe passes if a matching line has been written since the previous check,
+// so a Checker should not be reused across executions.
+//
+// A Checker is safe to access concurrently from multiple goroutines.
+type Checker struct {
+	probe task.Probe
+	cmd   script.Script
+	re    *regexp.Regexp
+
+	mu      *mutex.Mutex
+	partial []byte
+	matches int
+	seen    int
+	passed  time.Time // when a check last passed while monitoring
+}
+
+// *Checker implements io.Writer
+var _ io.Writer = &Checker{}
+
+// New creates a Checker for the given probe. Cmd probes run like cmd, as in
+// the same directory and environment, with the probe's command as its text.
+// The probe is assumed to be valid; see [task.Library.Validate].
+func New(p task.Probe, cmd script.Script) *Checker {
+	cmd.Text = p.Cmd
+	c := &Checker{
+		probe: p,
+		cmd:   cmd,
+		mu:    mutex.New("probe"),
+	}
+	if p.Log != "" {
+		c.re = regexp.MustCompile(p.Log)
+	}
+	return c
+}
+
+// LastPassed returns when a check made by [Checker.Monitor] last passed, or
+// the zero time if none has.
+func (c *Checker) LastPassed() time.Time {
+	defer c.mu.Lock("LastPassed").Unlock()
+	return c.passed
+}
+
+// Interval returns the time between attempts.
+func (c *Checker) Interval() time.Duration {
+	if c.probe.Interval > 0 {
+		return c.probe.Interval
+	}
+	return DefaultInterval
+}
+
+// Write scans task output for lines matching a log probe's pattern. For
+// other kinds of probe it discards its input.
+func (c *Checker) Write(bs []byte) (int, error) {
+	if c.re == nil {
+		return len(bs), nil
+	}
+	defer c.mu.Lock("Write").Unlock()
+	c.partial = append(c.partial, bs...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if c.re.Match(c.partial[:i]) {
+			c.matches++
+		}
+		c.partial = c.partial[i+1:]
+	}
+	if len(c.partial) > maxPartialLine {
+		if c.re.Match(c.partial) {
+			c.matches++
+		}
+		c.partial = nil
+	}
+	return len(bs), nil
+}
+
+// Check performs a single attempt, returning nil if the probe passes.
+func (c *Checker) Check(ctx context.Context) error {
+	switch {
+	case c.probe.HTTP != "":
+		return c.checkHTTP(ctx)
+	case c.probe.TCP != "":
+		return c.checkTCP(ctx)
+	case c.probe.Log != "":
+		return c.checkLog()
+	case c.probe.Cmd != "":
+		return c.checkCmd(ctx)
+	}
+	return nil
+}
+
+// Wait checks the probe every interval until it passes. If the probe's
+// Timeout elapses first, Wait returns an error describing the most recent
+// failure. If ctx is canceled first, Wait returns ctx.Err().
+func (c *Checker) Wait(ctx context.Context) error {
+	interval := c.Interval()
+
+	var deadline <-chan time.Time
+	if c.probe.Timeout > 0 {
+		timer := time.NewTimer(c.probe.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, interval)
+		err := c.Check(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("not ready after %s: %w", c.probe.Timeout, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Monitor checks the probe every interval, each check bounded by the probe's
+// Timeout, until ctx is canceled, in which case it returns ctx.Err(). If the
+// probe fails FailureThreshold times in a row, Monitor returns an error
+// describing the most recent failure.
+func (c *Checker) Monitor(ctx context.Context) error {
+	interval := c.Interval()
+	timeout := interval
+	if c.probe.Timeout > 0 {
+		timeout = c.probe.Timeout
+	}
+	threshold := DefaultFailureThreshold
+	if c.probe.FailureThreshold > 0 {
+		threshold = c.probe.FailureThreshold
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.Check(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			failures = 0
+			c.mu.Lock("Monitor")
+			c.passed = time.Now()
+			c.mu.Unlock()
+			continue
+		}
+		failures++
+		if failures >= threshold {
+			return fmt.Errorf("failed %d checks in a row: %w", failures, err)
+		}
+	}
+}
+
+func (c *Checker) checkHTTP(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.probe.HTTP, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s: %s", c.probe.HTTP, resp.Status)
+	}
+	return nil
+}
+
+func (c *Checker) checkTCP(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.probe.TCP)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (c *Checker) checkLog() error {
+	defer c.mu.Lock("checkLog").Unlock()
+	if c.matches > c.seen {
+		c.seen = c.matches
+		return nil
+	}
+	return fmt.Errorf("no output matching '%s'", c.probe.Log)
+}
+
+func (c *Checker) checkCmd(ctx context.Context) error {
+	if err := c.cmd.Start(ctx, io.Discard, io.Discard); err != nil {
+		return fmt.Errorf("%s: %w", c.probe.Cmd, err)
+	}
+	return nil
+}
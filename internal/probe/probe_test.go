@@ -0,0 +1,104 @@
+package probe_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"monks.co/run/internal/probe"
+	"monks.co/run/internal/script"
+	"monks.co/run/task"
+)
+
+func TestHTTP(t *testing.T) {
+	var unhealthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unhealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c := probe.New(task.Probe{HTTP: srv.URL}, script.Script{Dir: "."})
+	assert.NoError(t, c.Check(context.Background()))
+
+	unhealthy.Store(true)
+	assert.ErrorContains(t, c.Check(context.Background()), "503")
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	c := probe.New(task.Probe{TCP: addr}, script.Script{Dir: "."})
+	assert.NoError(t, c.Check(context.Background()))
+
+	ln.Close()
+	assert.Error(t, c.Check(context.Background()))
+}
+
+func TestCmd(t *testing.T) {
+	assert.NoError(t, probe.New(task.Probe{Cmd: "true"}, script.Script{Dir: "."}).Check(context.Background()))
+	assert.Error(t, probe.New(task.Probe{Cmd: "exit 3"}, script.Script{Dir: "."}).Check(context.Background()))
+}
+
+func TestLogMatchesSinceLastCheck(t *testing.T) {
+	c := probe.New(task.Probe{Log: "listening on :\\d+"}, script.Script{Dir: "."})
+	assert.Error(t, c.Check(context.Background()))
+
+	// A line split across writes only matches once it's complete.
+	c.Write([]byte("booting\nlistening "))
+	assert.Error(t, c.Check(context.Background()))
+	c.Write([]byte("on :8080\n"))
+	assert.NoError(t, c.Check(context.Background()))
+
+	// The match was consumed by the previous check.
+	assert.Error(t, c.Check(context.Background()))
+}
+
+func TestWaitTimeout(t *testing.T) {
+	c := probe.New(task.Probe{
+		Log:      "never",
+		Interval: 10 * time.Millisecond,
+		Timeout:  50 * time.Millisecond,
+	}, script.Script{Dir: "."})
+	err := c.Wait(context.Background())
+	assert.ErrorContains(t, err, "not ready after 50ms")
+}
+
+func TestWaitPasses(t *testing.T) {
+	c := probe.New(task.Probe{Log: "ready", Interval: 10 * time.Millisecond}, script.Script{Dir: "."})
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		c.Write([]byte("ready\n"))
+	}()
+	assert.NoError(t, c.Wait(context.Background()))
+}
+
+func TestMonitorFailureThreshold(t *testing.T) {
+	c := probe.New(task.Probe{
+		Log:              "tick",
+		Interval:         10 * time.Millisecond,
+		FailureThreshold: 2,
+	}, script.Script{Dir: "."})
+	c.Write([]byte("tick\n"))
+	start := time.Now()
+	err := c.Monitor(context.Background())
+	assert.ErrorContains(t, err, "failed 2 checks in a row")
+	assert.True(t, c.LastPassed().After(start))
+}
+
+func TestMonitorCanceled(t *testing.T) {
+	c := probe.New(task.Probe{Cmd: "true", Interval: 10 * time.Millisecond}, script.Script{Dir: "."})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Monitor(ctx), context.DeadlineExceeded)
+}
@@ -4,8 +4,9 @@
 // A Script is a value type describing what to run. Each call to Start creates
 // a fresh process; multiple Starts can run concurrently on the same Script.
 //
-// On context cancellation, Start sends SIGINT to the process group, waits up
-// to 2 seconds for a graceful exit, then sends SIGKILL.
+// On context cancellation, Start sends SIGINT (or a configured signal, or runs
+// a configured stop script) to the process group, waits up to 2 seconds (or a
+// configured grace period) for a graceful exit, then sends SIGKILL.
 package script
 
 import (
@@ -21,6 +22,7 @@ import (
 	"syscall"
 	"time"
 
+	"monks.co/run/internal/dotenv"
 	"monks.co/run/internal/mutex"
 )
 
@@ -30,17 +32,60 @@ type Script struct {
 	Dir  string
 	Env  []string
 	Text string
+
+	// EnvFiles are paths to .env files, which are read each time the
+	// script starts. Their variables are added to the environment in
+	// order, before Env, so that later files, and then Env, take
+	// precedence. Relative paths are relative to the current working
+	// directory rather than Dir. Files that don't exist are skipped.
+	EnvFiles []string
+
+	// Args are passed to the script as positional parameters, as in "$1"
+	// and "$@". If there are any, they are also set, joined by spaces, in
+	// the RUN_ARGS environment variable.
+	Args []string
+
+	// ChangedFiles are the paths of files whose changes caused the script
+	// to run. If there are any, they are set, separated by newlines, in
+	// the RUN_CHANGED_FILES environment variable, and written, one per
+	// line, to a temporary file whose path is set in
+	// RUN_CHANGED_FILES_LIST. The file is removed once the script exits.
+	ChangedFiles []string
+
+	// StopSignal is sent to the process group to stop the script when
+	// Start's context is canceled. If it is zero, Start uses SIGINT.
+	StopSignal syscall.Signal
+
+	// StopTimeout is how long Start waits for the script to exit after
+	// asking it to stop, before sending SIGKILL. If it is zero, Start
+	// uses DefaultStopTimeout.
+	StopTimeout time.Duration
+
+	// StopText optionally specifies a bash script, like "docker stop db",
+	// that stops the script more gracefully than a signal can. If it is
+	// set, Start runs it, in the script's Dir and environment, instead of
+	// sending StopSignal. Its output goes to the script's stdout and
+	// stderr. StopTimeout starts when StopText does, so it bounds both
+	// StopText and the wait for the script to exit: if StopText is still
+	// running once StopTimeout has passed, it's canceled, and the script
+	// is killed.
+	StopText string
 }
 
+// DefaultStopTimeout is how long Start waits for a stopped script to exit
+// before sending SIGKILL, for Scripts that don't specify a StopTimeout.
+const DefaultStopTimeout = 2 * time.Second
+
 // Start executes the script in a new bash process and blocks until it
 // completes or the context is canceled. It is safe to call Start multiple
 // times, including concurrently.
 //
 // stdout and stderr receive the script's respective output streams.
 //
-// On context cancellation, Start sends SIGINT to the process group, waits up
-// to 2 seconds, then sends SIGKILL. The returned error always includes
-// context.Canceled when the context is canceled before the script completes.
+// On context cancellation, Start stops the script by sending StopSignal to
+// the process group or by running StopText, waits up to StopTimeout, then
+// sends SIGKILL. The returned error always includes context.Canceled when the
+// context is canceled before the script completes.
 func (s Script) Start(ctx context.Context, stdout, stderr io.Writer) error {
 	return (&execution{
 		script: s,
@@ -56,13 +101,15 @@ type execution struct {
 	cmd    *exec.Cmd
 	stdout io.Writer
 	stderr io.Writer
+
+	changedFilesList string // path of the RUN_CHANGED_FILES_LIST file, if any
 }
 
 func (x *execution) run(ctx context.Context) error {
+	defer x.cleanup()
 	if err := x.startCmd(); err != nil {
 		return err
 	}
-	defer x.cleanup()
 
 	exit := x.wait()
 	select {
@@ -84,16 +131,26 @@ func (x *execution) run(ctx context.Context) error {
 	default:
 	}
 
-	// Try SIGINT first.
-	if err := x.sigint(); err != nil {
+	grace := x.script.StopTimeout
+	if grace <= 0 {
+		grace = DefaultStopTimeout
+	}
+	deadline := time.After(grace)
+
+	// Ask nicely first.
+	if x.script.StopText != "" {
+		if err := x.runStopText(grace); err != nil {
+			errs = append(errs, err)
+		}
+	} else if err := x.signal(x.stopSignal()); err != nil {
 		errs = append(errs, err)
 	}
 
-	// Give it 2 seconds to die gracefully.
+	// Give it the grace period to die gracefully.
 	select {
 	case <-exit:
 		return errors.Join(errs...)
-	case <-time.After(2 * time.Second):
+	case <-deadline:
 	}
 
 	// Resort to SIGKILL.
@@ -123,12 +180,33 @@ func (x *execution) startCmd() error {
 		return errFindingBash
 	}
 
-	x.cmd = exec.Command(bash, "-c", x.script.Text)
+	x.cmd = exec.Command(bash, append([]string{"-c", x.script.Text, bash}, x.script.Args...)...)
 	x.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	x.cmd.Dir = x.script.Dir
 	x.cmd.Stdout = x.stdout
 	x.cmd.Stderr = x.stderr
-	x.cmd.Env = append(os.Environ(), x.script.Env...)
+	env := os.Environ()
+	if len(x.script.EnvFiles) > 0 {
+		fileEnv, err := dotenv.Load(env, x.script.EnvFiles...)
+		if err != nil {
+			return err
+		}
+		env = append(env, fileEnv...)
+	}
+	if len(x.script.Args) > 0 {
+		env = append(env, "RUN_ARGS="+strings.Join(x.script.Args, " "))
+	}
+	if len(x.script.ChangedFiles) > 0 {
+		list, err := writeChangedFilesList(x.script.ChangedFiles)
+		if err != nil {
+			return err
+		}
+		x.changedFilesList = list
+		env = append(env,
+			"RUN_CHANGED_FILES="+strings.Join(x.script.ChangedFiles, "\n"),
+			"RUN_CHANGED_FILES_LIST="+list)
+	}
+	x.cmd.Env = append(env, x.script.Env...)
 
 	return x.cmd.Start()
 }
@@ -156,13 +234,31 @@ func (x *execution) wait() <-chan error {
 	return exit
 }
 
-func (x *execution) sigint() error {
-	defer x.mu.Lock("sigint").Unlock()
+func (x *execution) stopSignal() syscall.Signal {
+	if x.script.StopSignal != 0 {
+		return x.script.StopSignal
+	}
+	return syscall.SIGINT
+}
+
+func (x *execution) signal(sig syscall.Signal) error {
+	defer x.mu.Lock("signal").Unlock()
 	if x.cmd == nil {
 		return nil
 	}
-	if err := syscall.Kill(-x.cmd.Process.Pid, syscall.SIGINT); err != nil && !errors.Is(err, syscall.ESRCH) {
-		return fmt.Errorf("sigint error: %w", err)
+	if err := syscall.Kill(-x.cmd.Process.Pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("%s error: %w", sig, err)
+	}
+	return nil
+}
+
+// runStopText runs the script's stop script, giving up after timeout.
+func (x *execution) runStopText(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stop := Script{Dir: x.script.Dir, Env: x.script.Env, EnvFiles: x.script.EnvFiles, Text: x.script.StopText}
+	if err := stop.Start(ctx, x.stdout, x.stderr); err != nil {
+		return fmt.Errorf("stop script error: %w", err)
 	}
 	return nil
 }
@@ -181,6 +277,29 @@ func (x *execution) sigkill() error {
 func (x *execution) cleanup() {
 	defer x.mu.Lock("cleanup").Unlock()
 	x.cmd = nil
+	if x.changedFilesList != "" {
+		os.Remove(x.changedFilesList)
+		x.changedFilesList = ""
+	}
+}
+
+// writeChangedFilesList writes files, one per line, to a new temporary file,
+// and returns its path.
+func writeChangedFilesList(files []string) (string, error) {
+	f, err := os.CreateTemp("", "run-changed-files-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(strings.Join(files, "\n") + "\n"); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
 }
 
 func (x *execution) getCmd() *exec.Cmd {
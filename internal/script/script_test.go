@@ -3,7 +3,11 @@ package script_test
 import (
 	"bytes"
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -72,6 +76,32 @@ func TestEnv(t *testing.T) {
 	assert.Equal(t, "BAR\n", stdout.String())
 }
 
+func TestArgs(t *testing.T) {
+	s := script.Script{Dir: ".", Args: []string{"-run", "Test Foo"}, Text: `echo "$#" "$2" "$RUN_ARGS"`}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+
+	err := s.Start(context.Background(), stdout, stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2 Test Foo -run Test Foo\n", stdout.String())
+}
+
+func TestChangedFiles(t *testing.T) {
+	s := script.Script{
+		Dir:          ".",
+		ChangedFiles: []string{"/src/a.go", "/src/b c.go"},
+		Text:         `echo "$RUN_CHANGED_FILES"; cat "$RUN_CHANGED_FILES_LIST"; echo "$RUN_CHANGED_FILES_LIST"`,
+	}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+
+	err := s.Start(context.Background(), stdout, stderr)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Equal(t, []string{"/src/a.go", "/src/b c.go", "/src/a.go", "/src/b c.go"}, lines[:4])
+	assert.NoFileExists(t, lines[4], "the list is removed once the script exits")
+}
+
 func TestExitCode(t *testing.T) {
 	s := script.Script{Dir: ".", Text: "exit 1"}
 	stdout, stderr := &safeBuffer{}, &safeBuffer{}
@@ -141,3 +171,99 @@ func TestSIGKILL(t *testing.T) {
 		assert.ErrorIs(t, err, context.Canceled)
 	}
 }
+
+func TestStopSignal(t *testing.T) {
+	// Ignore SIGINT, but not SIGTERM.
+	s := script.Script{Dir: ".", Text: "trap '' SIGINT ; sleep 100", StopSignal: syscall.SIGTERM}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error)
+	go func() { errCh <- s.Start(ctx, stdout, stderr) }()
+
+	// Wait for the script to start.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("script did not exit after SIGTERM")
+	case err := <-errCh:
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := script.Script{Dir: ".", Text: "trap '' SIGINT ; sleep 100", StopTimeout: 100 * time.Millisecond}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error)
+	go func() { errCh <- s.Start(ctx, stdout, stderr) }()
+
+	// Wait for the script to start.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Should be killed well before the default grace period is up.
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("script was not killed after StopTimeout")
+	case err := <-errCh:
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestStopText(t *testing.T) {
+	stopFile := filepath.Join(t.TempDir(), "stop")
+	s := script.Script{
+		Dir:      ".",
+		Env:      []string{"FLAG=" + stopFile},
+		Text:     `trap '' SIGINT ; while [ ! -e "$FLAG" ]; do sleep 0.01; done ; echo stopped`,
+		StopText: `echo stopping ; touch "$FLAG"`,
+	}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error)
+	go func() { errCh <- s.Start(ctx, stdout, stderr) }()
+
+	// Wait for the script to start.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("script did not exit after its stop script ran")
+	case err := <-errCh:
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, "stopping\nstopped\n", stdout.String())
+	}
+}
+
+func TestEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	localFile := filepath.Join(dir, ".env.local")
+	assert.NoError(t, os.WriteFile(envFile, []byte("A=env\nB=env\nC=env\n"), 0o644))
+	assert.NoError(t, os.WriteFile(localFile, []byte("B=local\nC=local\n"), 0o644))
+
+	s := script.Script{
+		Dir:      ".",
+		Env:      []string{"C=inline"},
+		EnvFiles: []string{envFile, localFile},
+		Text:     "echo $A $B $C",
+	}
+	stdout, stderr := &safeBuffer{}, &safeBuffer{}
+
+	err := s.Start(context.Background(), stdout, stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "env local inline\n", stdout.String())
+
+	// Env files are reread each time the script starts.
+	assert.NoError(t, os.WriteFile(localFile, []byte("B=changed\n"), 0o644))
+	stdout = &safeBuffer{}
+	assert.NoError(t, s.Start(context.Background(), stdout, stderr))
+	assert.Equal(t, "env changed inline\n", stdout.String())
+}
@@ -0,0 +1,245 @@
+package watcher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/glob"
+)
+
+// ValidateIgnore returns an error if pattern isn't a valid ignore pattern.
+func ValidateIgnore(pattern string) error {
+	_, err := parseIgnore(pattern)
+	return err
+}
+
+// JoinIgnore rewrites an ignore pattern that's relative to dir to be
+// relative to the working directory, the way [filepath.Join] does for
+// paths. Patterns without a slash match names at any depth, so they're
+// returned unchanged.
+func JoinIgnore(dir, pattern string) string {
+	negate := ""
+	if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+		negate, pattern = "!", rest
+	}
+	trimmed, dirOnly := strings.CutSuffix(pattern, "/")
+	if !strings.Contains(trimmed, "/") {
+		return negate + pattern
+	}
+	joined := filepath.Join(dir, strings.TrimPrefix(trimmed, "/"))
+	if dirOnly {
+		joined += "/"
+	}
+	return negate + joined
+}
+
+type ignoreRule struct {
+	globs    []glob.Glob // the path matches if any of them match
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func parseIgnore(pattern string) (ignoreRule, error) {
+	var r ignoreRule
+	if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+		r.negate, pattern = true, rest
+	}
+	if rest, ok := strings.CutSuffix(pattern, "/"); ok {
+		r.dirOnly, pattern = true, rest
+	}
+	if strings.Contains(pattern, "/") {
+		r.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if pattern == "" {
+		return r, errors.New("empty ignore pattern")
+	}
+
+	for _, p := range expandDoubleStar(pattern) {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return r, err
+		}
+		r.globs = append(r.globs, g)
+	}
+	return r, nil
+}
+
+// expandDoubleStar returns the patterns that, together, match what pattern
+// does in .gitignore syntax, where "**/" matches zero or more directories,
+// rather than one or more.
+func expandDoubleStar(pattern string) []string {
+	if rest, ok := strings.CutPrefix(pattern, "**/"); ok {
+		return append(prefixAll("**/", expandDoubleStar(rest)), expandDoubleStar(rest)...)
+	}
+	before, after, ok := strings.Cut(pattern, "/**/")
+	if !ok {
+		return []string{pattern}
+	}
+	return slices.Concat(
+		prefixAll(before+"/**/", expandDoubleStar(after)),
+		prefixAll(before+"/", expandDoubleStar(after)),
+	)
+}
+
+func prefixAll(prefix string, ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = prefix + s
+	}
+	return out
+}
+
+// match reports whether the rule matches the slash-separated path rel.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	for _, g := range r.globs {
+		if g.Match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFiles are the names of the files that [Options.IgnoreFiles] honors,
+// in the order they're read.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// An ignorer decides which event paths a watch drops.
+type ignorer struct {
+	rules []ignoreRule
+	files bool
+
+	mu    sync.Mutex
+	cache map[string][]ignoreRule // rules from ignore files, by directory
+}
+
+func newIgnorer(opts Options) (*ignorer, error) {
+	ig := &ignorer{files: opts.IgnoreFiles, cache: map[string][]ignoreRule{}}
+	for _, pattern := range opts.Ignore {
+		r, err := parseIgnore(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern '%s': %w", pattern, err)
+		}
+		ig.rules = append(ig.rules, r)
+	}
+	return ig, nil
+}
+
+// ignored returns true if events for p, a path relative to the working
+// directory, should be dropped. If p is itself an ignore file, ignored
+// first forgets the rules it had read from it.
+func (ig *ignorer) ignored(p string) bool {
+	if len(ig.rules) == 0 && !ig.files {
+		return false
+	}
+	if ig.files && slices.Contains(ignoreFiles, filepath.Base(p)) {
+		ig.mu.Lock()
+		delete(ig.cache, filepath.Dir(p))
+		ig.mu.Unlock()
+	}
+
+	elems := strings.Split(filepath.ToSlash(p), "/")
+	for n := range elems {
+		if elems[n] == "" {
+			continue
+		}
+		isDir := n < len(elems)-1
+		if !isDir && ig.needsDir() {
+			info, err := os.Stat(p)
+			isDir = err == nil && info.IsDir()
+		}
+		if ig.ignoredPrefix(elems[:n+1], filepath.IsAbs(p), isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredPrefix returns true if the path made of elems is ignored by the
+// ignore files or the rules, without regard to its parent directories.
+// Later rules take precedence over earlier ones, and the rules take
+// precedence over the ignore files, deeper ones over shallower ones.
+func (ig *ignorer) ignoredPrefix(elems []string, abs, isDir bool) bool {
+	ignored := false
+	if ig.files && !abs {
+		if elems[len(elems)-1] == ".git" {
+			return true
+		}
+		for i := range elems {
+			if elems[i] == ".." {
+				// The path is outside dir, so dir's ignore
+				// files don't apply to it.
+				continue
+			}
+			dir := path.Join(append([]string{"."}, elems[:i]...)...)
+			rel := strings.Join(elems[i:], "/")
+			for _, r := range ig.fileRules(dir) {
+				if r.match(rel, isDir) {
+					ignored = !r.negate
+				}
+			}
+		}
+	}
+	rel := strings.Join(elems, "/")
+	for _, r := range ig.rules {
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// needsDir returns true if some of the ignorer's rules may only match
+// directories, so that it must check whether event paths are directories.
+func (ig *ignorer) needsDir() bool {
+	for _, r := range ig.rules {
+		if r.dirOnly {
+			return true
+		}
+	}
+	return ig.files
+}
+
+// fileRules returns the rules from the ignore files in dir.
+func (ig *ignorer) fileRules(dir string) []ignoreRule {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+	if rules, ok := ig.cache[dir]; ok {
+		return rules
+	}
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimRight(s.Text(), " \t\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			// Skip patterns that don't parse, as git does.
+			if r, err := parseIgnore(line); err == nil {
+				rules = append(rules, r)
+			}
+		}
+		f.Close()
+	}
+	ig.cache[dir] = rules
+	return rules
+}
@@ -0,0 +1,228 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/rjeczalik/notify"
+)
+
+// selfTestTimeout is how long [ModeAuto] waits for an event for its test
+// file before it decides that notify doesn't work.
+const selfTestTimeout = time.Second
+
+// selfTestPrefix begins the names of [ModeAuto]'s test files. Every watch
+// drops their events, so that a self-test in a directory that's already
+// watched doesn't look like a change.
+const selfTestPrefix = ".run-watch-test-"
+
+// isSelfTestFile returns true if p is one of [ModeAuto]'s test files.
+func isSelfTestFile(p string) bool {
+	return strings.HasPrefix(filepath.Base(p), selfTestPrefix)
+}
+
+// fileState is what a polling watch compares to find changes to a path.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// watchPoll implements [Watch] by comparing snapshots of the files under
+// watchPath every interval.
+func watchPoll(watchPath string, globToMatch glob.Glob, ig *ignorer, interval time.Duration) (<-chan []EventInfo, func(), error) {
+	root, recursive := strings.CutSuffix(watchPath, "...")
+	root = filepath.Clean(root)
+	snap := func() (map[string]fileState, error) {
+		return snapshot(root, recursive, ig)
+	}
+
+	prev, err := snap()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan []EventInfo)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			cur, err := snap()
+			if err != nil {
+				// The root is gone, so everything under it is too.
+				cur = map[string]fileState{}
+			}
+			evs := diff(prev, cur, globToMatch)
+			prev = cur
+			if len(evs) == 0 {
+				continue
+			}
+			select {
+			case out <- evs:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+	return out, stop, nil
+}
+
+// snapshot returns the state of the paths a polling watch of root sees, by
+// their paths in the same form as root: root's entries, or, if recursive is
+// set, everything under root. Ignored paths are left out, and ignored
+// directories aren't descended into.
+func snapshot(root string, recursive bool, ig *ignorer) (map[string]fileState, error) {
+	snap := map[string]fileState{}
+	add := func(p string, info fs.FileInfo) bool {
+		if isSelfTestFile(p) || ig.ignored(p) {
+			return false
+		}
+		snap[p] = fileState{size: info.Size(), modTime: info.ModTime(), mode: info.Mode()}
+		return true
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		add(root, info)
+		return snap, nil
+	}
+
+	if !recursive {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				add(filepath.Join(root, e.Name()), info)
+			}
+		}
+		return snap, nil
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip paths that vanish or can't be read mid-walk.
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !add(p, info) && d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return snap, err
+}
+
+// diff returns the events that turn prev into cur, for paths that match
+// globToMatch, in order by path. Since directories' modification times
+// change whenever their entries do, only their creation and removal are
+// reported.
+func diff(prev, cur map[string]fileState, globToMatch glob.Glob) []EventInfo {
+	var evs []EventInfo
+	for p, st := range cur {
+		old, ok := prev[p]
+		switch {
+		case !ok:
+			evs = append(evs, EventInfo{Path: p, Event: "Create"})
+		case old != st && !st.mode.IsDir():
+			evs = append(evs, EventInfo{Path: p, Event: "Write"})
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			evs = append(evs, EventInfo{Path: p, Event: "Remove"})
+		}
+	}
+	evs = slices.DeleteFunc(evs, func(ev EventInfo) bool {
+		return globToMatch != nil && !globToMatch.Match(ev.Path)
+	})
+	slices.SortFunc(evs, func(a, b EventInfo) int { return strings.Compare(a.Path, b.Path) })
+	return evs
+}
+
+var (
+	selfTestMu      sync.Mutex
+	selfTestResults = map[string]bool{}
+)
+
+// notifyWorks returns true if notify reports events in the directory that
+// watchPath watches, or if that can't be tested. It tests by creating and
+// removing a temporary file there, once per directory.
+func notifyWorks(watchPath string) bool {
+	dir := filepath.Clean(strings.TrimSuffix(watchPath, "..."))
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+
+	selfTestMu.Lock()
+	defer selfTestMu.Unlock()
+	if works, ok := selfTestResults[dir]; ok {
+		return works
+	}
+	works := selfTest(dir)
+	selfTestResults[dir] = works
+	return works
+}
+
+func selfTest(dir string) bool {
+	c := make(chan notify.EventInfo, 8)
+	if err := notify.Watch(dir, c, notify.Create|notify.Remove); err != nil {
+		// Watch will report the error itself.
+		return true
+	}
+	defer notify.Stop(c)
+
+	f, err := os.CreateTemp(dir, selfTestPrefix+"*")
+	if err != nil {
+		// There's no way to test a directory we can't write to.
+		return true
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	timeout := time.After(selfTestTimeout)
+	for {
+		select {
+		case ev := <-c:
+			if filepath.Base(ev.Path()) == filepath.Base(name) {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
@@ -1,10 +1,14 @@
 // Package watcher provides file system watching with debouncing and glob
-// matching. It also provides mock support for testing.
+// matching, using either the operating system's file events or polling. It
+// also provides mock support for testing.
 package watcher
 
 import (
+	"cmp"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,35 +17,123 @@ import (
 	"github.com/rjeczalik/notify"
 )
 
+// batchWindow is how long [Watch] gathers events into a batch, starting with
+// the first, before it sends them.
+const batchWindow = 20 * time.Millisecond
+
 // EventInfo describes a single file system event.
 type EventInfo struct {
 	Path  string
 	Event string
 }
 
+// Options configure a [Watch].
+type Options struct {
+	// Ignore lists patterns for paths whose events are dropped. Patterns
+	// use .gitignore syntax:
+	//   - a pattern without a slash, like "node_modules" or "*.log",
+	//     matches a file or directory with that name at any depth.
+	//   - a pattern with a slash, like "web/dist" or "build/**", matches
+	//     paths relative to the working directory. "*" doesn't match a
+	//     slash, but "**" does.
+	//   - a trailing slash, as in "tmp/", matches only directories.
+	//   - a leading "!" un-ignores paths that an earlier pattern, or an
+	//     ignore file, ignored.
+	//
+	// A path inside an ignored directory is ignored too.
+	Ignore []string
+
+	// IgnoreFiles drops events for paths that are ignored by .gitignore
+	// and .ignore files in the working directory and its subdirectories,
+	// and for paths in .git directories.
+	IgnoreFiles bool
+
+	// Mode is how the watch finds changes: [ModeNotify], [ModePoll], or
+	// [ModeAuto]. If it's empty, it's ModeNotify.
+	Mode string
+
+	// PollInterval is how often a polling watch checks for changes. If
+	// it's zero, it's [DefaultPollInterval].
+	PollInterval time.Duration
+
+	// Notice, if it's set, is called with messages about the watch, as
+	// when ModeAuto falls back to polling.
+	Notice func(msg string)
+}
+
+// Watch modes.
+const (
+	// ModeNotify watches with the operating system's file events.
+	ModeNotify = "notify"
+
+	// ModePoll checks the watched files' sizes, modification times, and
+	// modes every PollInterval, for file systems that don't report events,
+	// like Docker Desktop bind mounts, NFS, and some VMs' shared folders.
+	ModePoll = "poll"
+
+	// ModeAuto uses ModeNotify unless a self-test, which creates and
+	// removes a temporary file in the watched directory, gets no event
+	// for it, in which case it uses ModePoll.
+	ModeAuto = "auto"
+)
+
+// DefaultPollInterval is how often a polling watch checks for changes if
+// [Options] doesn't say.
+const DefaultPollInterval = time.Second
+
 // Watch observes the file system at inputPath and returns a channel of
-// debounced events, a stop function, and any error. The inputPath may contain
-// glob patterns (e.g., "src/website/**/*.js").
+// batched events, a stop function, and any error. The inputPath may contain
+// glob patterns (e.g., "src/website/**/*.js"). Event paths are in the same
+// form as inputPath: relative to the working directory, even if they start
+// with "../", if it's relative, and absolute if it's absolute. Events for
+// paths that opts ignores are dropped before they're batched.
+//
+// Events that arrive within a short window of each other are batched
+// together, but Watch doesn't otherwise debounce them; callers decide how
+// long to wait for changes to stop.
+//
+// opts.Mode chooses how Watch finds changes; see [ModeNotify], [ModePoll],
+// and [ModeAuto].
 //
 // Watch is a package-level function variable to allow replacement for testing
 // via Mock.
-var Watch = func(inputPath string) (<-chan []EventInfo, func(), error) {
-	var stopped bool
-
-	cwdRaw, err := os.Getwd()
+var Watch = func(inputPath string, opts Options) (<-chan []EventInfo, func(), error) {
+	ig, err := newIgnorer(opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	// kqueue (FreeBSD, NetBSD, OpenBSD) and FSEvents report event paths
-	// with symlinks resolved. If the cwd is reached through a symlink
-	// (e.g. FreeBSD's /home -> /usr/home), the raw cwd will not be a
-	// prefix of the event path, so also keep the resolved form.
-	cwdResolved, rerr := filepath.EvalSymlinks(cwdRaw)
-	if rerr != nil {
-		cwdResolved = ""
+	if opts.PollInterval < 0 {
+		return nil, nil, fmt.Errorf("negative poll interval %s", opts.PollInterval)
 	}
-
 	watchPath, globToMatch := Split(inputPath)
+	interval := cmp.Or(opts.PollInterval, DefaultPollInterval)
+
+	switch opts.Mode {
+	case ModeNotify, "":
+		return watchNotify(watchPath, globToMatch, ig)
+	case ModePoll:
+		return watchPoll(watchPath, globToMatch, ig, interval)
+	case ModeAuto:
+		if !notifyWorks(watchPath) {
+			if opts.Notice != nil {
+				opts.Notice(fmt.Sprintf("no file events in %s; polling every %s instead", inputPath, interval))
+			}
+			return watchPoll(watchPath, globToMatch, ig, interval)
+		}
+		return watchNotify(watchPath, globToMatch, ig)
+	default:
+		return nil, nil, fmt.Errorf("unknown watch mode '%s'", opts.Mode)
+	}
+}
+
+// watchNotify implements [Watch] with the operating system's file events.
+func watchNotify(watchPath string, globToMatch glob.Glob, ig *ignorer) (<-chan []EventInfo, func(), error) {
+	var stopped bool
+
+	rel, err := relativizer(watchPath)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Start listening for events.
 	c := make(chan notify.EventInfo, 1)
@@ -49,8 +141,11 @@ var Watch = func(inputPath string) (<-chan []EventInfo, func(), error) {
 
 	go func() {
 		for ev := range c {
-			p := StripCwd(ev.Path(), cwdRaw, cwdResolved)
-			if globToMatch == nil || globToMatch.Match(p) {
+			p := rel(ev.Path())
+			if isSelfTestFile(p) {
+				continue
+			}
+			if (globToMatch == nil || globToMatch.Match(p)) && !ig.ignored(p) {
 				out <- EventInfo{
 					Path:  p,
 					Event: strings.TrimPrefix(ev.Event().String(), "notify."),
@@ -75,7 +170,61 @@ var Watch = func(inputPath string) (<-chan []EventInfo, func(), error) {
 		return nil, nil, err
 	}
 
-	return Debounce(500*time.Millisecond, out), stop, nil
+	return Debounce(batchWindow, out), stop, nil
+}
+
+// relativizer returns a function that puts the absolute paths that notify
+// reports for events under watchPath in the same form as watchPath, so that
+// they can be matched against globs and ignore patterns written like it. If
+// watchPath is relative, event paths are made relative to the working
+// directory, even if that means starting with "../".
+func relativizer(watchPath string) (func(string) string, error) {
+	cwdRaw, cwdResolved, err := cwd()
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Clean(strings.TrimSuffix(watchPath, "..."))
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// Event paths may have symlinks resolved; see cwd.
+	rootResolved, err := filepath.EvalSymlinks(rootAbs)
+	if err != nil {
+		rootResolved = ""
+	}
+	return func(eventPath string) string {
+		for _, prefix := range []string{rootAbs, rootResolved} {
+			if prefix == "" {
+				continue
+			}
+			if eventPath == prefix {
+				return root
+			}
+			if s, ok := strings.CutPrefix(eventPath, strings.TrimSuffix(prefix, "/")+"/"); ok {
+				return filepath.Join(root, s)
+			}
+		}
+		return StripCwd(eventPath, cwdRaw, cwdResolved)
+	}, nil
+}
+
+// cwd returns the current working directory, as Getwd reports it and with
+// symlinks resolved, for [StripCwd].
+func cwd() (raw, resolved string, err error) {
+	raw, err = os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	// kqueue (FreeBSD, NetBSD, OpenBSD) and FSEvents report event paths
+	// with symlinks resolved. If the cwd is reached through a symlink
+	// (e.g. FreeBSD's /home -> /usr/home), the raw cwd will not be a
+	// prefix of the event path, so also keep the resolved form.
+	resolved, rerr := filepath.EvalSymlinks(raw)
+	if rerr != nil {
+		resolved = ""
+	}
+	return raw, resolved, nil
 }
 
 // StripCwd returns eventPath made relative to the current working directory.
@@ -161,10 +310,15 @@ func Split(input string) (string, glob.Glob) {
 // --- Mock support ---
 
 var (
-	mockMu       sync.Mutex
-	mockChannels map[string]chan []EventInfo
+	mockMu      sync.Mutex
+	mockWatches map[string][]*mockWatch
 )
 
+type mockWatch struct {
+	ch chan []EventInfo
+	ig *ignorer
+}
+
 // Mock replaces Watch with an implementation that captures calls and allows
 // synthetic events via Dispatch. It returns a restore function that must be
 // called to reinstate the real Watch.
@@ -173,37 +327,51 @@ func Mock() func() {
 	defer mockMu.Unlock()
 
 	original := Watch
-	mockChannels = make(map[string]chan []EventInfo)
+	mockWatches = make(map[string][]*mockWatch)
+
+	Watch = func(inputPath string, opts Options) (<-chan []EventInfo, func(), error) {
+		ig, err := newIgnorer(opts)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	Watch = func(inputPath string) (<-chan []EventInfo, func(), error) {
 		mockMu.Lock()
 		defer mockMu.Unlock()
 
-		ch := make(chan []EventInfo, 16)
-		mockChannels[inputPath] = ch
+		w := &mockWatch{ch: make(chan []EventInfo, 16), ig: ig}
+		mockWatches[inputPath] = append(mockWatches[inputPath], w)
 		stop := func() {
 			mockMu.Lock()
 			defer mockMu.Unlock()
-			delete(mockChannels, inputPath)
+			mockWatches[inputPath] = slices.DeleteFunc(mockWatches[inputPath], func(o *mockWatch) bool { return o == w })
 		}
-		return ch, stop, nil
+		return w.ch, stop, nil
 	}
 
 	return func() {
 		mockMu.Lock()
 		defer mockMu.Unlock()
 		Watch = original
-		mockChannels = nil
+		mockWatches = nil
 	}
 }
 
-// Dispatch sends synthetic events to the mock watcher for the given path.
-// The path must match the inputPath previously passed to Watch.
+// Dispatch sends synthetic events to the mock watchers for the given path.
+// The path must match the inputPath previously passed to Watch. Like Watch,
+// each watcher drops the events that its options ignore.
 func Dispatch(path string, evs ...EventInfo) {
 	mockMu.Lock()
-	ch, ok := mockChannels[path]
+	ws := slices.Clone(mockWatches[path])
 	mockMu.Unlock()
-	if ok {
-		ch <- evs
+	for _, w := range ws {
+		var kept []EventInfo
+		for _, ev := range evs {
+			if !w.ig.ignored(ev.Path) {
+				kept = append(kept, ev)
+			}
+		}
+		if len(kept) > 0 {
+			w.ch <- kept
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package watcher_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 	"testing/synctest"
@@ -76,7 +77,7 @@ func TestMockAndDispatch(t *testing.T) {
 		restore := watcher.Mock()
 		defer restore()
 
-		ch, stop, err := watcher.Watch("src/**/*.go")
+		ch, stop, err := watcher.Watch("src/**/*.go", watcher.Options{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -117,7 +118,7 @@ func TestWatchReturnsErrorForNonexistentPath(t *testing.T) {
 	// notify.Watch should reject a path that does not exist. The previous
 	// implementation swallowed that error and returned a silent no-op
 	// watcher, which made failures on kqueue systems impossible to diagnose.
-	_, stop, err := watcher.Watch(filepath.Join(t.TempDir(), "does-not-exist"))
+	_, stop, err := watcher.Watch(filepath.Join(t.TempDir(), "does-not-exist"), watcher.Options{})
 	if err == nil {
 		if stop != nil {
 			stop()
@@ -155,3 +156,258 @@ func TestStripCwdEmptyResolved(t *testing.T) {
 		t.Errorf("expected 'file.go', got %q", got)
 	}
 }
+
+func TestIgnore(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"web/.gitignore", "web/dist/.keep", "src/sub/.keep"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(p, content string) {
+		if err := os.WriteFile(filepath.Join(dir, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(".gitignore", "# build output\n*.tmp\n/out\n")
+	writeFile("web/.gitignore", "dist/\n!keep.tmp\n")
+	t.Chdir(dir)
+
+	cases := []struct {
+		opts    watcher.Options
+		path    string
+		ignored bool
+	}{
+		{watcher.Options{Ignore: []string{"node_modules"}}, "node_modules/a.js", true},
+		{watcher.Options{Ignore: []string{"node_modules"}}, "web/node_modules/pkg/a.js", true},
+		{watcher.Options{Ignore: []string{"node_modules"}}, "src/a.js", false},
+		{watcher.Options{Ignore: []string{"*.log"}}, "src/sub/a.log", true},
+		{watcher.Options{Ignore: []string{"src/*.go"}}, "src/a.go", true},
+		{watcher.Options{Ignore: []string{"src/*.go"}}, "src/sub/a.go", false},
+		{watcher.Options{Ignore: []string{"src/**/*.go"}}, "src/a.go", true},
+		{watcher.Options{Ignore: []string{"src/**/*.go"}}, "src/sub/a.go", true},
+		{watcher.Options{Ignore: []string{"src/sub/"}}, "src/sub/a.go", true},
+		{watcher.Options{Ignore: []string{"src/sub/"}}, "src/sub", true},
+		{watcher.Options{Ignore: []string{"src/a.go/"}}, "src/a.go", false},
+		{watcher.Options{Ignore: []string{"*.go", "!main.go"}}, "src/main.go", false},
+		{watcher.Options{}, "a.tmp", false},
+		{watcher.Options{IgnoreFiles: true}, "a.tmp", true},
+		{watcher.Options{IgnoreFiles: true}, "src/sub/a.tmp", true},
+		{watcher.Options{IgnoreFiles: true}, "out/a.go", true},
+		{watcher.Options{IgnoreFiles: true}, "src/out/a.go", false},
+		{watcher.Options{IgnoreFiles: true}, "web/dist/a.js", true},
+		{watcher.Options{IgnoreFiles: true}, "web/keep.tmp", false},
+		{watcher.Options{IgnoreFiles: true}, "dist/a.js", false},
+		{watcher.Options{IgnoreFiles: true}, ".git/index", true},
+		{watcher.Options{IgnoreFiles: true}, "src/a.go", false},
+		{watcher.Options{IgnoreFiles: true, Ignore: []string{"!a.tmp"}}, "a.tmp", false},
+	}
+	for _, c := range cases {
+		restore := watcher.Mock()
+		ch, stop, err := watcher.Watch(".", c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watcher.Dispatch(".", watcher.EventInfo{Path: c.path, Event: "Write"})
+		select {
+		case <-ch:
+			if c.ignored {
+				t.Errorf("%+v: expected %s to be ignored", c.opts, c.path)
+			}
+		default:
+			if !c.ignored {
+				t.Errorf("%+v: expected %s not to be ignored", c.opts, c.path)
+			}
+		}
+		stop()
+		restore()
+	}
+}
+
+func TestIgnoreFileChanges(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(".gitignore", []byte("*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	restore := watcher.Mock()
+	defer restore()
+	ch, stop, err := watcher.Watch(".", watcher.Options{IgnoreFiles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	watcher.Dispatch(".", watcher.EventInfo{Path: "a.tmp", Event: "Write"})
+	select {
+	case <-ch:
+		t.Fatal("expected a.tmp to be ignored")
+	default:
+	}
+
+	// Changes to an ignore file take effect right away.
+	if err := os.WriteFile(".gitignore", []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	watcher.Dispatch(".", watcher.EventInfo{Path: ".gitignore", Event: "Write"})
+	<-ch
+	watcher.Dispatch(".", watcher.EventInfo{Path: "a.tmp", Event: "Write"})
+	select {
+	case <-ch:
+	default:
+		t.Fatal("expected a.tmp not to be ignored once .gitignore changed")
+	}
+}
+
+func TestInvalidIgnorePattern(t *testing.T) {
+	if err := watcher.ValidateIgnore("src/[a"); err == nil {
+		t.Error("expected an error for an unclosed character class")
+	}
+	if _, _, err := watcher.Watch(".", watcher.Options{Ignore: []string{"src/[a"}}); err == nil {
+		t.Error("expected Watch to reject an invalid ignore pattern")
+	}
+}
+
+func TestJoinIgnore(t *testing.T) {
+	for _, c := range [][3]string{
+		{"web", "node_modules", "node_modules"},
+		{"web", "*.log", "*.log"},
+		{"web", "dist/**", "web/dist/**"},
+		{"web", "/out", "web/out"},
+		{"web", "tmp/cache/", "web/tmp/cache/"},
+		{"web", "!src/keep.js", "!web/src/keep.js"},
+		{".", "dist/**", "dist/**"},
+	} {
+		if got := watcher.JoinIgnore(c[0], c[1]); got != c[2] {
+			t.Errorf("JoinIgnore(%q, %q) = %q, want %q", c[0], c[1], got, c[2])
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("src/node_modules", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, stop, err := watcher.Watch("src/*.go", watcher.Options{
+		Mode:         watcher.ModePoll,
+		PollInterval: 10 * time.Millisecond,
+		Ignore:       []string{"node_modules"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	next := func() []watcher.EventInfo {
+		t.Helper()
+		select {
+		case evs := <-ch:
+			return evs
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for polled events")
+			return nil
+		}
+	}
+
+	// Ignored paths and paths that don't match the glob aren't reported.
+	for _, p := range []string{"src/node_modules/dep.go", "src/README.md", "src/main.go"} {
+		if err := os.WriteFile(p, []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if evs := next(); len(evs) != 1 || evs[0] != (watcher.EventInfo{Path: "src/main.go", Event: "Create"}) {
+		t.Errorf("expected a Create event for src/main.go, got %v", evs)
+	}
+
+	// Make sure the modification time changes, even on coarse file systems.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes("src/main.go", later, later); err != nil {
+		t.Fatal(err)
+	}
+	if evs := next(); len(evs) != 1 || evs[0] != (watcher.EventInfo{Path: "src/main.go", Event: "Write"}) {
+		t.Errorf("expected a Write event for src/main.go, got %v", evs)
+	}
+
+	if err := os.Remove("src/main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if evs := next(); len(evs) != 1 || evs[0] != (watcher.EventInfo{Path: "src/main.go", Event: "Remove"}) {
+		t.Errorf("expected a Remove event for src/main.go, got %v", evs)
+	}
+}
+
+func TestPollNonexistentPath(t *testing.T) {
+	_, _, err := watcher.Watch(filepath.Join(t.TempDir(), "does-not-exist"), watcher.Options{Mode: watcher.ModePoll})
+	if err == nil {
+		t.Fatal("expected error for nonexistent path, got nil")
+	}
+}
+
+func TestAutoUsesNotify(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	var notices []string
+	ch, stop, err := watcher.Watch(".", watcher.Options{
+		Mode:   watcher.ModeAuto,
+		Notice: func(msg string) { notices = append(notices, msg) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if len(notices) > 0 {
+		t.Fatalf("expected notify to pass its self-test, got %v", notices)
+	}
+
+	// The self-test's file isn't reported.
+	if err := os.WriteFile("main.go", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evs := <-ch:
+		for _, ev := range evs {
+			if ev.Path != "main.go" {
+				t.Errorf("unexpected event %v", ev)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for events")
+	}
+}
+
+func TestAutoSelfTestIsInvisible(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ch, stop, err := watcher.Watch(".", watcher.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// A self-test in a directory that's already watched doesn't produce
+	// events there.
+	_, stopAuto, err := watcher.Watch("./...", watcher.Options{Mode: watcher.ModeAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopAuto()
+	select {
+	case evs := <-ch:
+		t.Fatalf("unexpected events %v", evs)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestUnknownMode(t *testing.T) {
+	if _, _, err := watcher.Watch(".", watcher.Options{Mode: "psychic"}); err == nil {
+		t.Fatal("expected an error for an unknown watch mode")
+	}
+}
+
+func TestNegativePollInterval(t *testing.T) {
+	if _, _, err := watcher.Watch(".", watcher.Options{Mode: watcher.ModePoll, PollInterval: -time.Second}); err == nil {
+		t.Fatal("expected an error for a negative poll interval")
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"monks.co/run/cache"
 	"monks.co/run/internal/color"
 	"monks.co/run/printer"
 	"monks.co/run/runner"
@@ -28,20 +34,31 @@ import (
 // --- Invocation types ---
 
 type RunInvocation struct {
-	Task string   `pos:"0" required:"true"`
-	Dir  string   `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory."`
-	Skip []string `flag:"skip" usage:"Skip a task, replacing it with a no-op stub. Can be passed more than once."`
-	UI   string   `flag:"ui" usage:"Force a particular ui. Legal values are 'tui' and 'printer'."`
+	Tasks []string `pos:"0" required:"true"`
+	Args  []string `pos:"--"`
+	Dir   string   `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory, or else in its nearest parent directory that has one."`
+	Skip  []string `flag:"skip" usage:"Skip a task, replacing it with a no-op stub. Can be passed more than once."`
+	Set   []string `flag:"set" usage:"Set a taskfile variable, as in -set KEY=VALUE, overriding any value from the taskfile. Can be passed more than once."`
+	UI    string   `flag:"ui" usage:"Force a particular ui. Legal values are 'tui' and 'printer'."`
+	Mode  string   `flag:"mode" usage:"Run the printer ui in a particular mode. Legal values are 'short', which exits once the tasks finish, and 'long', which keeps restarting tasks and watching files until it gets a signal. The tui only supports 'long', so with -mode=short, the printer ui is used."`
+
+	Timeout   time.Duration `flag:"timeout" usage:"Cancel the run and exit with an error if it hasn't finished after the given duration, like 10m or 1h30m."`
+	Force     bool          `flag:"force" usage:"Run tasks with sources even if they're up to date."`
+	Jobs      int           `flag:"j" usage:"Run at most the given number of short tasks at once, overriding the taskfile's concurrency setting."`
+	KeepGoing bool          `flag:"keep-going" usage:"When a task fails, keep running the tasks that don't depend on it, and list every failure at the end."`
+
+	WatchMode    string        `flag:"watch-mode" usage:"Choose how to watch files for changes. Legal values are 'notify', which uses the operating system's file events, 'poll', which checks for changes periodically, for file systems without events like Docker bind mounts and NFS, and 'auto', which uses 'notify' unless a self-test gets no events. Defaults to $RUN_WATCH_MODE, or else 'notify'."`
+	PollInterval time.Duration `flag:"poll-interval" usage:"How often to check for changes with -watch-mode=poll. Defaults to 1s."`
 }
 
 type InspectInvocation struct {
 	Task string `pos:"0"`
-	Dir  string `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory."`
+	Dir  string `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory, or else in its nearest parent directory that has one."`
 	List bool   `flag:"list" required:"true" usage:"Display the task list and exit. If run is invoked with both -list and a task ID, that task's dependencies are displayed."`
 }
 
 type SessionInvocation struct {
-	Dir     string `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory."`
+	Dir     string `flag:"dir" default:"." usage:"Look for a root taskfile in the given directory, or else in its nearest parent directory that has one."`
 	Name    string `flag:"session" required:"true" usage:"The name of the task you started (e.g. dev)."`
 	Status  bool   `flag:"status" usage:"Show whether each task is running, failed, or done."`
 	Restart string `flag:"restart" usage:"Restart a task. Useful after changing code."`
@@ -49,6 +66,10 @@ type SessionInvocation struct {
 	Nolog   string `flag:"nolog" usage:"Stop writing a task's output to its log file."`
 }
 
+type CacheInvocation struct {
+	Prune string `flag:"cache-prune" required:"true" usage:"Remove the least recently used task outputs from the cache until it's no bigger than the given size, like 500MB or 2GB."`
+}
+
 type InfoInvocation struct {
 	Version      bool `flag:"version" usage:"Display the version and exit."`
 	Help         bool `flag:"help" usage:"Display the help text and exit."`
@@ -62,18 +83,19 @@ type InfoInvocation struct {
 var runInv RunInvocation
 var inspectInv InspectInvocation
 var sessionInv SessionInvocation
+var cacheInv CacheInvocation
 var infoInv InfoInvocation
 
 var modes = []mode{
 	{
 		name:        "RUNNING TASKS",
-		description: "Execute a task and its dependencies.",
-		usage:       "run [flags] <task>",
+		description: "Execute one or more tasks and their dependencies. Arguments after -- are passed to those tasks' cmds, as $@ and as $RUN_ARGS.",
+		usage:       "run [flags] <task>... [-- args...]",
 		inv:         &runInv,
 	},
 	{
 		name: "INTERACTING WITH RUNNING TASKS",
-		description: "In addition to the TUI, you can check on and control long-running tasks from the command line. Use -session with the name of the task you started (e.g. if you ran `run dev`, use -session=dev).",
+		description: "In addition to the TUI, you can check on and control long-running tasks from the command line. Use -session with the name of the task you started (e.g. if you ran `run dev`, use -session=dev). If you started several tasks, join their names with +, as in api+web.",
 		examples: []string{
 			"run -session=<name> -status",
 			"run -session=<name> -restart=<task>",
@@ -91,6 +113,14 @@ var modes = []mode{
 		},
 		inv: &inspectInv,
 	},
+	{
+		name:        "MANAGING THE CACHE",
+		description: "Run caches the outputs of tasks with sources and outputs, so that it can restore them rather than running the tasks again.",
+		examples: []string{
+			"run -cache-prune=<size>",
+		},
+		inv: &cacheInv,
+	},
 	{
 		name: "ABOUT",
 		inv:  &infoInv,
@@ -125,6 +155,8 @@ func main() {
 		handleInspect()
 	case *SessionInvocation:
 		handleSession()
+	case *CacheInvocation:
+		handleCache()
 	case *RunInvocation:
 		handleRun()
 	}
@@ -146,6 +178,7 @@ func handleInfo() {
 }
 
 func handleInspect() {
+	inspectInv.Dir = findTaskfile(inspectInv.Dir)
 	allTasks, err := taskfile.Load(inspectInv.Dir, inspectInv.Task)
 	if err != nil {
 		fmt.Println("Error loading tasks:")
@@ -165,6 +198,9 @@ func handleInspect() {
 }
 
 func handleSession() {
+	if dir, err := taskfile.Find(sessionInv.Dir); err == nil {
+		sessionInv.Dir = dir
+	}
 	absDir, err := filepath.Abs(sessionInv.Dir)
 	if err != nil {
 		fmt.Printf("Error resolving directory: %s\n", err)
@@ -226,14 +262,52 @@ func handleSession() {
 	}
 }
 
+func handleCache() {
+	size, err := parseSize(cacheInv.Prune)
+	if err != nil {
+		fmt.Printf("Invalid value %q for flag -cache-prune: %s\n", cacheInv.Prune, err)
+		os.Exit(1)
+	}
+	removed, err := cache.NewDir(session.CacheDir()).Prune(size)
+	if err != nil {
+		fmt.Printf("Error pruning cache: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("removed %d cache entries\n", removed)
+}
+
 func handleRun() {
-	allTasks, err := taskfile.Load(runInv.Dir, runInv.Task)
+	vars := map[string]string{}
+	for _, kv := range runInv.Set {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			fmt.Printf("Invalid value %q for flag -set. Use -set KEY=VALUE.\n", kv)
+			os.Exit(1)
+		}
+		vars[k] = v
+	}
+
+	runInv.Dir = findTaskfile(runInv.Dir)
+	settings, err := taskfile.LoadSettings(runInv.Dir)
 	if err != nil {
 		fmt.Println("Error loading tasks:")
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	allTasks, err := taskfile.LoadWithVars(runInv.Dir, vars, slices.Concat(runInv.Tasks, settings.OnExit)...)
+	if err != nil {
+		fmt.Println("Error loading tasks:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if runInv.Jobs < 0 {
+		fmt.Println("Invalid value for flag -j. It must not be negative.")
+		os.Exit(1)
+	} else if runInv.Jobs > 0 {
+		settings.Concurrency = runInv.Jobs
+	}
+
 	if len(runInv.Skip) > 0 {
 		for _, id := range runInv.Skip {
 			if !allTasks.Has(id) {
@@ -256,11 +330,13 @@ func handleRun() {
 		allTasks = task.NewLibrary(tasks...)
 	}
 
-	taskID := runInv.Task
-	if !allTasks.Has(taskID) {
-		fmt.Printf("Task %q not found.\n", taskID)
-		fmt.Println("Run `run -list` for more information about the available tasks.")
-		os.Exit(1)
+	taskIDs := runInv.Tasks
+	for _, taskID := range taskIDs {
+		if !allTasks.Has(taskID) {
+			fmt.Printf("Task %q not found.\n", taskID)
+			fmt.Println("Run `run -list` for more information about the available tasks.")
+			os.Exit(1)
+		}
 	}
 
 	stdoutIsTTY := term.IsTerminal(int(os.Stdout.Fd()))
@@ -272,9 +348,11 @@ func handleRun() {
 	case "printer":
 		useTUI = false
 	case "":
-		if stdoutIsTTY {
-			if allTasks.Get(taskID).Metadata().Type == "long" {
-				useTUI = true
+		if stdoutIsTTY && runInv.Mode != "short" {
+			for _, taskID := range taskIDs {
+				if allTasks.Get(taskID).Metadata().Type == "long" {
+					useTUI = true
+				}
 			}
 		}
 	default:
@@ -282,22 +360,90 @@ func handleRun() {
 		os.Exit(1)
 	}
 
+	runType := runner.RunTypeShort
+	switch runInv.Mode {
+	case "short", "":
+	case "long":
+		runType = runner.RunTypeLong
+	default:
+		fmt.Println("Invalid value for flag -mode. Legal values are 'short' and 'long'.")
+		os.Exit(1)
+	}
+	if useTUI && runInv.Mode == "short" {
+		fmt.Println("Invalid value for flag -mode. The tui only supports 'long'; use -ui=printer with -mode=short.")
+		os.Exit(1)
+	}
+
+	watchMode := cmp.Or(runInv.WatchMode, os.Getenv("RUN_WATCH_MODE"))
+	switch watchMode {
+	case "", "notify", "poll", "auto":
+	default:
+		fmt.Println("Invalid value for flag -watch-mode. Legal values are 'notify', 'poll', and 'auto'.")
+		os.Exit(1)
+	}
+	if runInv.PollInterval < 0 {
+		fmt.Println("Invalid value for flag -poll-interval. It must not be negative.")
+		os.Exit(1)
+	}
+
+	// On the first signal, stop the tasks gracefully, in reverse dependency
+	// order. Stop listening then, so that a second signal kills run
+	// outright.
 	ctx, stop := signal.NotifyContext(context.Background(),
 		syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	absDir, err := filepath.Abs(runInv.Dir)
+	if err != nil {
+		fmt.Printf("Error resolving directory: %s\n", err)
+		os.Exit(1)
+	}
+
+	opts := []runner.Option{
+		runner.WithTimeout(runInv.Timeout),
+		runner.WithArgs(runInv.Args...),
+		runner.WithStateDir(session.StateDir(absDir)),
+		runner.WithForce(runInv.Force),
+		runner.WithCache(cache.NewDir(session.CacheDir())),
+		runner.WithConcurrency(settings.Concurrency),
+		runner.WithResources(settings.Resources),
+		runner.WithKeepGoing(runInv.KeepGoing),
+		runner.WithOnExit(settings.OnExit...),
+		runner.WithDebounce(settings.Debounce),
+		runner.WithThrottle(settings.Throttle),
+		runner.WithWatchMode(watchMode, runInv.PollInterval),
+	}
 
 	var runErr error
 	if useTUI {
-		runErr = tui.Start(ctx, os.Stdin, os.Stdout, runInv.Dir, allTasks, taskID)
+		runErr = tui.Start(ctx, os.Stdin, os.Stdout, runInv.Dir, allTasks, taskIDs, opts...)
 	} else {
-		subtree := allTasks.Subtree(taskID)
+		subtree := allTasks.Subtree(taskIDs...)
 		prn := printer.New(subtree.LongestID(), os.Stdout, stdoutIsTTY)
-		r, err := runner.New(runner.RunTypeShort, runInv.Dir, allTasks, taskID, prn, runner.WithInteractive(stdoutIsTTY))
+		opts = append(opts, runner.WithInteractive(stdoutIsTTY))
+		r, err := runner.New(runType, runInv.Dir, allTasks, taskIDs, prn, opts...)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		// Long runs get a session, like the tui's, so that they can be
+		// checked on and controlled with -session.
+		var sess *session.Session
+		if runType == runner.RunTypeLong {
+			sess, err = session.New(strings.Join(taskIDs, "+"), absDir, r, prn.Send)
+			if err != nil {
+				fmt.Printf("Warning: session not created: %s\n", err)
+			}
+		}
 		runErr = r.Start(ctx)
+		if sess != nil {
+			sess.Close()
+		}
+		prn.Close()
 	}
 
 	if runErr != nil && errors.Is(runErr, context.Canceled) {
@@ -309,6 +455,48 @@ func handleRun() {
 	}
 }
 
+// findTaskfile returns the nearest directory at or above dir that contains a
+// taskfile, exiting if there isn't one.
+func findTaskfile(dir string) string {
+	found, err := taskfile.Find(dir)
+	if err != nil {
+		fmt.Println("Error loading tasks:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return found
+}
+
+// sizeUnits are the units parseSize accepts, by suffix.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a size in bytes, like "500MB" or "2GB". A size without a
+// unit is in bytes.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = strings.TrimSpace(n), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("use a size like 500MB or 2GB")
+	}
+	return int64(n * float64(unit)), nil
+}
+
 // --- Help text helpers ---
 
 func tasklistText(tasks task.Library) string {
@@ -328,6 +516,12 @@ func tasklistText(tasks task.Library) string {
 			desc := strings.TrimRight(dedent.String(meta.Description), "\n")
 			b.WriteString(indent.String(italicStyle.Render(desc), 6) + "\n")
 		}
+		if len(meta.Matrix) != 0 {
+			fmt.Fprintf(b, "    Matrix:\n")
+			for _, k := range slices.Sorted(maps.Keys(meta.Matrix)) {
+				fmt.Fprintf(b, "      - %s: %s\n", k, strings.Join(meta.Matrix[k], ", "))
+			}
+		}
 		if len(meta.Dependencies) != 0 {
 			fmt.Fprintf(b, "    Dependencies:\n")
 			for _, dep := range meta.Dependencies {
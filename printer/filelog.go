@@ -0,0 +1,87 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "charm.land/bubbletea/v2"
+	"monks.co/run/runner"
+	"monks.co/run/session"
+)
+
+// Send handles the messages that a [session.Session] sends to its UI to turn
+// file logging on and off, so that a Printer can back a session, as in
+//
+//	session.New(name, dir, run, printer.Send)
+//
+// Unlike the TUI, a Printer doesn't keep its streams' history, so a log file
+// only gets a stream's output from when logging was enabled. Send ignores
+// other messages.
+func (p *Printer) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case session.EnableFileLogMsg:
+		err := p.enableFileLog(msg.TaskID, msg.Path)
+		if msg.Reply != nil {
+			msg.Reply <- err == nil
+		}
+		if err == nil {
+			p.write(msg.TaskID, runner.LogStyle.Render(fmt.Sprintf("logging to %s", msg.Path))+"\n")
+		}
+
+	case session.DisableFileLogMsg:
+		p.disableFileLog(msg.TaskID)
+		if msg.Reply != nil {
+			msg.Reply <- true
+		}
+		p.write(msg.TaskID, runner.LogStyle.Render("file logging disabled")+"\n")
+
+	case session.QueryFileLogMsg:
+		p.mu.Lock("Send:query")
+		_, logging := p.logFiles[msg.TaskID]
+		p.mu.Unlock()
+		if msg.Reply != nil {
+			msg.Reply <- logging
+		}
+	}
+}
+
+func (p *Printer) enableFileLog(id, path string) error {
+	defer p.mu.Lock("enableFileLog").Unlock()
+	if _, ok := p.keys[id]; !ok {
+		return fmt.Errorf("unknown stream '%s'", id)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if old := p.logFiles[id]; old != nil {
+		old.Close()
+	}
+	p.logFiles[id] = f
+	return nil
+}
+
+func (p *Printer) disableFileLog(id string) {
+	defer p.mu.Lock("disableFileLog").Unlock()
+	if f := p.logFiles[id]; f != nil {
+		f.Close()
+		delete(p.logFiles, id)
+	}
+}
+
+// Close closes the log files that a session enabled, as once the run that
+// the Printer displays is over.
+func (p *Printer) Close() error {
+	defer p.mu.Lock("Close").Unlock()
+	var errs []error
+	for id, f := range p.logFiles {
+		errs = append(errs, f.Close())
+		delete(p.logFiles, id)
+	}
+	return errors.Join(errs...)
+}
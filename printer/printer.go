@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"charm.land/lipgloss/v2"
@@ -34,6 +35,8 @@ func New(gutterWidth int, stdout io.Writer, color bool) *Printer {
 		stdout:    stdout,
 		keyLength: gutterWidth,
 		color:     color,
+		keys:      map[string]struct{}{},
+		logFiles:  map[string]*os.File{},
 	}
 }
 
@@ -45,12 +48,18 @@ type Printer struct {
 	keyLength int
 	lastKey   string
 	color     bool
+
+	keys     map[string]struct{} // IDs of the streams written so far
+	logFiles map[string]*os.File // files that streams are logged to
 }
 
 // *Printer implements MultiWriter
 var _ runner.MultiWriter = &Printer{}
 
 func (p *Printer) Writer(id string) io.Writer {
+	p.mu.Lock("Writer")
+	p.keys[id] = struct{}{}
+	p.mu.Unlock()
 	return printerWriter{p, id}
 }
 
@@ -85,6 +94,10 @@ func (p *Printer) write(key, message string) {
 		panic("nil stdout in printer")
 	}
 
+	if f := p.logFiles[key]; f != nil {
+		f.WriteString(runner.StripANSIEscapeCodes(message))
+	}
+
 	lines := strings.SplitSeq(message, "\n")
 	for l := range lines {
 		if l == "" {
@@ -1,10 +1,13 @@
 package printer
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"monks.co/run/session"
 )
 
 // When color is disabled (non-interactive output — piped to a file or shipped
@@ -33,3 +36,43 @@ func TestPrinterColorPrintsContent(t *testing.T) {
 	assert.Contains(t, out, "monks_air")
 	assert.Contains(t, out, "hello")
 }
+
+// A session can turn file logging on and off for a printer's streams. Log
+// files get the stream's output, without the gutter or ANSI escapes.
+func TestPrinterFileLog(t *testing.T) {
+	var sb strings.Builder
+	p := New(len("api"), &sb, true)
+	w := p.Writer("api")
+	w.Write([]byte("before\n"))
+
+	path := filepath.Join(t.TempDir(), "logs", "api.log")
+	reply := make(chan bool, 1)
+	p.Send(session.EnableFileLogMsg{TaskID: "api", Path: path, Reply: reply})
+	assert.True(t, <-reply)
+	p.Send(session.QueryFileLogMsg{TaskID: "api", Reply: reply})
+	assert.True(t, <-reply)
+
+	w.Write([]byte("\x1b[1mduring\x1b[0m\n"))
+	p.Send(session.DisableFileLogMsg{TaskID: "api", Reply: reply})
+	assert.True(t, <-reply)
+	w.Write([]byte("after\n"))
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "logging to "+path+"\nduring\n", string(b))
+	assert.Contains(t, sb.String(), "after")
+
+	p.Send(session.EnableFileLogMsg{TaskID: "web", Path: path, Reply: reply})
+	assert.False(t, <-reply)
+
+	// Closing the printer stops logging.
+	p.Send(session.EnableFileLogMsg{TaskID: "api", Path: path, Reply: reply})
+	assert.True(t, <-reply)
+	assert.NoError(t, p.Close())
+	p.Send(session.QueryFileLogMsg{TaskID: "api", Reply: reply})
+	assert.False(t, <-reply)
+	w.Write([]byte("closed\n"))
+	b, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "logging to "+path+"\n", string(b))
+}
@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"monks.co/run/cache"
+	"monks.co/run/internal/fingerprint"
+	"monks.co/run/task"
+)
+
+// WithCache sets a cache for the outputs of short tasks with sources and
+// outputs. After such a task succeeds, its outputs are stored in the cache,
+// keyed by the fingerprint of its sources. When the task would run again
+// with a fingerprint that's in the cache, its outputs are restored from the
+// cache instead.
+func WithCache(c cache.Cache) Option {
+	return func(r *Run) { r.cache = c }
+}
+
+// canRestore returns true if a task with metadata tm and fingerprint fp
+// might have its outputs restored from the cache.
+func (r *Run) canRestore(tm task.TaskMetadata, fp string) bool {
+	return r.cache != nil && !r.force && fp != "" && len(tm.Outputs) > 0
+}
+
+// restoreOutputs restores t's outputs from the cache entry for fp, returning
+// true if there was one. Since it may copy many files, it runs in t's
+// executor rather than the event loop.
+func (r *Run) restoreOutputs(t task.Task, fp string) bool {
+	tm := t.Metadata()
+	if !r.canRestore(tm, fp) {
+		return false
+	}
+	rc, err := r.cache.Get(fp)
+	if errors.Is(err, cache.ErrNotFound) {
+		return false
+	} else if err != nil {
+		r.printf(tm.ID, logStyle, "error reading cache: %s", err)
+		return false
+	}
+	defer rc.Close()
+	if err := extract(rc, r.dir); err != nil {
+		r.printf(tm.ID, logStyle, "error restoring outputs from cache: %s", err)
+		return false
+	}
+	return true
+}
+
+// storeOutputs stores t's outputs in the cache entry for fp. Like
+// restoreOutputs, it runs in t's executor.
+func (r *Run) storeOutputs(t task.Task, fp string) {
+	tm := t.Metadata()
+	if r.cache == nil || len(tm.Outputs) == 0 {
+		return
+	}
+	files, err := fingerprint.Files(r.dir, tm.Outputs)
+	if err != nil {
+		r.printf(tm.ID, logStyle, "error caching outputs: %s", err)
+		return
+	}
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(archive(pw, r.dir, files)) }()
+	if err := r.cache.Put(fp, pr); err != nil {
+		r.printf(tm.ID, logStyle, "error caching outputs: %s", err)
+	}
+	pr.Close()
+}
+
+// archive writes a gzipped tarball of the files, which are relative to dir,
+// to w.
+func archive(w io.Writer, dir string, files []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range files {
+		if err := addFile(tw, dir, name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func addFile(tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extract writes the files in the gzipped tarball read from r into dir,
+// replacing any that exist.
+func extract(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) || hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("unexpected entry '%s' in cached outputs", hdr.Name)
+		}
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := writeFile(p, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+func writeFile(p string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"slices"
+
+	"monks.co/run/task"
+)
+
+// WithConcurrency limits how many short tasks may run at once. Short tasks
+// that would exceed the limit wait in a queue, in the order that they became
+// ready to run. Long tasks don't count toward the limit. By default, or if n
+// is 0, there is no limit.
+func WithConcurrency(n int) Option {
+	return func(r *Run) { r.concurrency = n }
+}
+
+// WithResources sets how many tasks may use each named resource at once, for
+// tasks whose [task.TaskMetadata] lists Resources. A resource that isn't
+// given a limit may be used by one task at a time.
+func WithResources(limits map[string]int) Option {
+	return func(r *Run) { r.resources = limits }
+}
+
+// acquire claims a concurrency slot for the task with the given ID, along
+// with its resources, returning true if the task may start. If the run's
+// limits don't allow it to start yet, acquire queues it, to be started by
+// [Run.release] once they do. Long tasks, and tasks that already hold a
+// slot, may always start.
+func (r *Run) acquire(t task.Task) bool {
+	tm := t.Metadata()
+	if tm.Type != "short" || (r.concurrency == 0 && len(tm.Resources) == 0) {
+		return true
+	}
+
+	r.mu.Lock("acquire")
+	_, holding := r.slots[tm.ID]
+	ok := holding || r.hasSlot(tm)
+	queued := slices.Contains(r.queue, tm.ID)
+	if !ok && !queued {
+		r.queue = append(r.queue, tm.ID)
+		r.taskStatus[tm.ID] = TaskStatusQueued
+	} else if ok && !holding {
+		r.reserve(tm)
+	}
+	r.mu.Unlock()
+
+	if !ok && !queued {
+		r.printf(tm.ID, logStyle, "queued")
+	}
+	return ok
+}
+
+// release returns the slot and resources held by the task with the given
+// ID, if any, and starts as many queued tasks as the run's limits allow.
+func (r *Run) release(id string) {
+	r.mu.Lock("release")
+	if resources, holding := r.slots[id]; holding {
+		delete(r.slots, id)
+		for _, name := range resources {
+			r.resourceUse[name]--
+		}
+	}
+	r.queue = slices.DeleteFunc(r.queue, func(qid string) bool {
+		return qid == id || !r.tasks.Has(qid)
+	})
+	var ready []string
+	for _, qid := range r.queue {
+		tm := r.tasks.Get(qid).Metadata()
+		if r.hasSlot(tm) {
+			r.reserve(tm)
+			ready = append(ready, qid)
+		}
+	}
+	r.queue = slices.DeleteFunc(r.queue, func(qid string) bool {
+		return slices.Contains(ready, qid)
+	})
+	r.mu.Unlock()
+
+	for _, id := range ready {
+		r.input <- msgRunTask(id)
+	}
+}
+
+// hasSlot returns true if the run's limits allow tm to start. The caller must
+// hold r.mu.
+func (r *Run) hasSlot(tm task.TaskMetadata) bool {
+	if r.concurrency > 0 && len(r.slots) >= r.concurrency {
+		return false
+	}
+	for _, name := range tm.Resources {
+		if r.resourceUse[name] >= r.resourceLimit(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// reserve gives tm a slot, and marks its resources as in use. The caller must
+// hold r.mu.
+func (r *Run) reserve(tm task.TaskMetadata) {
+	r.slots[tm.ID] = tm.Resources
+	for _, name := range tm.Resources {
+		r.resourceUse[name]++
+	}
+}
+
+// resourceLimit returns how many tasks may use the named resource at once.
+func (r *Run) resourceLimit(name string) int {
+	if n, ok := r.resources[name]; ok && n > 0 {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"cmp"
+	"time"
+)
+
+// defaultDebounce is how long a task waits for changes to the paths it
+// watches to stop, if neither it nor the run sets a debounce.
+const defaultDebounce = 500 * time.Millisecond
+
+// WithDebounce sets how long a task waits, after a change to a path it
+// watches, for changes to stop before it reruns, for tasks that don't
+// specify their own Debounce. By default, it's 500 milliseconds.
+func WithDebounce(d time.Duration) Option {
+	return func(r *Run) { r.debounce = d }
+}
+
+// WithThrottle sets the minimum time between a task's reruns due to changes
+// to paths it watches, for tasks that don't specify their own Throttle. By
+// default, there is none.
+func WithThrottle(d time.Duration) Option {
+	return func(r *Run) { r.throttle = d }
+}
+
+// A watchTrigger is a task's debounce state: a pending rerun, if any, and
+// when the task was last rerun because of a watched change.
+type watchTrigger struct {
+	timer *time.Timer
+	gen   int // incremented each time the timer is reset, to spot stale fires
+	last  time.Time
+}
+
+// msgWatchTrigger is sent when a task's debounce timer fires.
+type msgWatchTrigger struct {
+	id  string
+	gen int
+}
+
+// scheduleWatchRun schedules a rerun of the task with the given ID after a
+// change to a path it watches, once changes have stopped for the task's
+// debounce and its throttle has passed since its last watch-triggered rerun.
+// Each change resets the wait.
+func (r *Run) scheduleWatchRun(id string) {
+	tm := r.tasks.Get(id).Metadata()
+	debounce := cmp.Or(tm.Debounce, r.debounce, defaultDebounce)
+	throttle := cmp.Or(tm.Throttle, r.throttle)
+
+	defer r.mu.Lock("scheduleWatchRun").Unlock()
+	wt := r.watchTriggers[id]
+	if wt == nil {
+		wt = &watchTrigger{}
+		r.watchTriggers[id] = wt
+	}
+	now := time.Now()
+	at := now.Add(debounce)
+	if next := wt.last.Add(throttle); throttle > 0 && !wt.last.IsZero() && next.After(at) {
+		at = next
+	}
+	if wt.timer != nil {
+		wt.timer.Stop()
+	}
+	wt.gen++
+	msg := msgWatchTrigger{id: id, gen: wt.gen}
+	wt.timer = time.AfterFunc(at.Sub(now), func() { r.input <- msg })
+}
+
+// handleWatchTrigger reruns a task once its debounce timer fires.
+func (r *Run) handleWatchTrigger(msg msgWatchTrigger) {
+	r.mu.Lock("handleWatchTrigger")
+	wt := r.watchTriggers[msg.id]
+	if wt == nil || wt.gen != msg.gen {
+		// The timer was reset or the task was removed after this fired.
+		r.mu.Unlock()
+		return
+	}
+	wt.timer = nil
+	wt.last = time.Now()
+	r.restartAttempts[msg.id] = 0
+	r.mu.Unlock()
+
+	r.printf(InternalTaskWatch, logStyle, "invalidating {%s}", msg.id)
+	r.input <- msgRunTask(msg.id)
+}
+
+// stopWatchTrigger cancels any pending rerun of the task with the given ID
+// and forgets its debounce state. The caller must hold r.mu.
+func (r *Run) stopWatchTrigger(id string) {
+	if wt := r.watchTriggers[id]; wt != nil && wt.timer != nil {
+		wt.timer.Stop()
+	}
+	delete(r.watchTriggers, id)
+}
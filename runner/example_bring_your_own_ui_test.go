@@ -27,7 +27,7 @@ func Example_bringYourOwnUI() {
 		log.Fatal(err)
 	}
 
-	run, err := runner.New(runner.RunTypeLong, ".", tasks, "dev", ui{})
+	run, err := runner.New(runner.RunTypeLong, ".", tasks, []string{"dev"}, ui{})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -13,5 +13,5 @@ import (
 // which isn't too much more complex.
 func Example() {
 	tasks, _ := taskfile.Load(".")
-	tui.Start(context.Background(), os.Stdin, os.Stdout, ".", tasks, "dev")
+	tui.Start(context.Background(), os.Stdin, os.Stdout, ".", tasks, []string{"dev"})
 }
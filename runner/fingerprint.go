@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"monks.co/run/internal/fingerprint"
+	"monks.co/run/task"
+)
+
+// WithStateDir sets a directory where the run keeps state between runs, like
+// the fingerprints of tasks with sources. Without one, tasks with sources
+// always run.
+func WithStateDir(dir string) Option {
+	return func(r *Run) { r.stateDir = dir }
+}
+
+// WithForce makes the run start tasks with sources even if they're up to
+// date. Their fingerprints are still recorded.
+func WithForce(force bool) Option {
+	return func(r *Run) { r.force = force }
+}
+
+// checkFingerprint returns the current fingerprint of t's sources, or "" if
+// t doesn't have sources or the run has neither a state dir nor a cache.
+// upToDate is true if the fingerprint matches the one recorded after t last
+// succeeded, and all of t's outputs exist, so that t needn't run. Since it
+// hashes all of t's sources, it runs in t's executor rather than the event
+// loop.
+func (r *Run) checkFingerprint(t task.Task) (fp string, upToDate bool) {
+	tm := t.Metadata()
+	if !r.fingerprints(tm) {
+		return "", false
+	}
+	fp, err := fingerprint.Sum(r.dir, tm.Sources, r.fingerprintParts(t)...)
+	if err != nil {
+		r.printf(tm.ID, logStyle, "error fingerprinting sources: %s", err)
+		return "", false
+	}
+	if r.force || r.stateDir == "" {
+		return fp, false
+	}
+	recorded, err := os.ReadFile(r.fingerprintPath(tm.ID))
+	if err != nil || strings.TrimSpace(string(recorded)) != fp {
+		return fp, false
+	}
+	for _, pattern := range tm.Outputs {
+		files, err := fingerprint.Files(r.dir, []string{pattern})
+		if err != nil || len(files) == 0 {
+			return fp, false
+		}
+	}
+	return fp, true
+}
+
+// fingerprints returns true if the run fingerprints the sources of the task
+// with metadata tm.
+func (r *Run) fingerprints(tm task.TaskMetadata) bool {
+	return (r.stateDir != "" || r.cache != nil) && len(tm.Sources) > 0
+}
+
+// recordFingerprint records fp as the fingerprint of the task with the given
+// ID's sources as of its last success.
+func (r *Run) recordFingerprint(id, fp string) {
+	if r.stateDir == "" {
+		return
+	}
+	p := r.fingerprintPath(id)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		r.printf(id, logStyle, "error recording fingerprint: %s", err)
+		return
+	}
+	if err := os.WriteFile(p, []byte(fp+"\n"), 0o644); err != nil {
+		r.printf(id, logStyle, "error recording fingerprint: %s", err)
+	}
+}
+
+func (r *Run) fingerprintPath(id string) string {
+	return filepath.Join(r.stateDir, "fingerprints", url.PathEscape(id))
+}
+
+// fingerprintParts returns the parts of t's definition, besides its sources,
+// that its fingerprint covers: its outputs; for script tasks, its script and
+// environment; and, for root tasks, the run's args.
+func (r *Run) fingerprintParts(t task.Task) []string {
+	parts := []string{strings.Join(t.Metadata().Outputs, "\x00")}
+	if f, ok := t.(interface{ Fingerprint() string }); ok {
+		parts = append(parts, f.Fingerprint())
+	}
+	if slices.Contains(r.rootIDs, t.Metadata().ID) {
+		parts = append(parts, strings.Join(r.args, "\x00"))
+	}
+	return parts
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"monks.co/run/internal/executor"
+	"monks.co/run/internal/probe"
+	"monks.co/run/task"
+)
+
+// startTask runs t, closing onReady once t has signaled readiness and, if t
+// has a readiness probe, once the probe has passed. If the probe times out,
+// startTask cancels the task and returns the probe's error.
+func (r *Run) startTask(ctx context.Context, t task.Task, onReady chan<- struct{}, w io.Writer) error {
+	tm := t.Metadata()
+	if tm.Ready == nil {
+		return t.Start(ctx, onReady, w)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	checker := probe.New(*tm.Ready, r.taskScript(t, ""))
+	taskReady := make(chan struct{})
+	go func() {
+		select {
+		case <-taskReady:
+		case <-ctx.Done():
+			return
+		}
+		if err := checker.Wait(ctx); err != nil {
+			cancel(err)
+			return
+		}
+		r.printf(tm.ID, logStyle, "ready")
+		close(onReady)
+	}()
+
+	err := t.Start(ctx, taskReady, io.MultiWriter(w, checker))
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
+
+// monitorLiveness evaluates a ready task's liveness probe until the task
+// exits, reporting the task unhealthy if the probe fails too many times in a
+// row. readyAt is when the task became ready.
+func (r *Run) monitorLiveness(id string, exec *executor.Executor, c *probe.Checker, readyAt time.Time) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-exec.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := c.Monitor(ctx); err != nil && ctx.Err() == nil {
+		msg := msgTaskUnhealthy{id: id, err: err, exec: exec}
+		if passed := c.LastPassed(); !passed.IsZero() {
+			msg.healthyFor = passed.Sub(readyAt)
+		}
+		r.input <- msg
+	}
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"monks.co/run/task"
+)
+
+// InternalTaskExit is the ID used for the output of the run's on-exit tasks.
+const InternalTaskExit = "@exit"
+
+// defaultOnExitTimeout is how long each on-exit task may take, if the run
+// doesn't say.
+const defaultOnExitTimeout = time.Minute
+
+// WithOnExit sets tasks that [Run.Start] runs, one after another, once the
+// run is over and its tasks have been stopped, whether the run succeeded,
+// failed, or was canceled. They're useful for cleanup, like dropping test
+// databases or stopping containers. Their output goes to the
+// [InternalTaskExit] stream. Like hooks, they must be short tasks without
+// dependencies or hooks of their own.
+//
+// An on-exit task that fails doesn't stop the rest. If the run would
+// otherwise have succeeded, Start returns the failure; otherwise, it's only
+// reported.
+func WithOnExit(ids ...string) Option {
+	return func(r *Run) { r.onExit = ids }
+}
+
+// WithOnExitTimeout limits how long each on-exit task may take, so that a
+// hung cleanup can't keep [Run.Start] from returning. An on-exit task that
+// runs longer is canceled, and fails with an error wrapping [ErrTimedOut].
+// By default, the limit is 1 minute.
+func WithOnExitTimeout(d time.Duration) Option {
+	return func(r *Run) { r.exitTimeout = d }
+}
+
+// runWithHooks runs t's before hooks, then start, then t's after hooks,
+// stopping at the first failure. Whatever happens, it then runs t's finally
+// hooks, even if ctx has been canceled.
+func (r *Run) runWithHooks(ctx context.Context, t task.Task, w io.Writer, start func(context.Context) error) error {
+	tm := t.Metadata()
+	err := r.runHooks(ctx, tm.ID, "before", tm.Before, w)
+	if err == nil {
+		err = start(ctx)
+	}
+	if err == nil {
+		err = r.runHooks(ctx, tm.ID, "after", tm.After, w)
+	}
+	if len(tm.Finally) == 0 {
+		return err
+	}
+
+	// Finally hooks run even as the run shuts down, so they mustn't
+	// inherit its cancellation. Their failures mustn't mask the task's
+	// own.
+	finallyErr := r.runHooks(context.WithoutCancel(ctx), tm.ID, "finally", tm.Finally, w)
+	if err == nil {
+		return finallyErr
+	} else if finallyErr != nil {
+		r.printf(tm.ID, logStyle, "%s", finallyErr)
+	}
+	return err
+}
+
+// runHooks runs the hook tasks with the given IDs one after another, writing
+// their output to w and announcing each in the stream with the given ID. It
+// stops at the first failure, returning its error.
+func (r *Run) runHooks(ctx context.Context, id, kind string, hookIDs []string, w io.Writer) error {
+	for _, hookID := range hookIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.printf(id, logStyle, "%s: %s", kind, hookID)
+		if err := r.allTasks.Get(hookID).Start(ctx, make(chan struct{}), w); err != nil {
+			return fmt.Errorf("%s hook '%s' failed: %w", kind, hookID, err)
+		}
+	}
+	return nil
+}
+
+// runOnExit runs the run's on-exit tasks, returning an error joining their
+// failures, if any.
+func (r *Run) runOnExit() error {
+	timeout := cmp.Or(r.exitTimeout, defaultOnExitTimeout)
+	var errs []error
+	for _, id := range r.onExit {
+		r.printf(InternalTaskExit, logStyle, "%s: starting", id)
+		w := r.writer(InternalTaskExit)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.allTasks.Get(id).Start(ctx, make(chan struct{}), w)
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("%w after %s", ErrTimedOut, timeout)
+		}
+		cancel()
+		if err != nil {
+			r.printf(InternalTaskExit, logStyle, "%s: exit: %s", id, err)
+			errs = append(errs, fmt.Errorf("on-exit task '%s' failed: %w", id, err))
+		} else {
+			r.printf(InternalTaskExit, logStyle, "%s: exit ok", id)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writer returns the writer for the stream with the given ID, or
+// [io.Discard] if there isn't one.
+func (r *Run) writer(id string) io.Writer {
+	defer r.mu.Lock("writer").Unlock()
+	if w := r.writers[id]; w != nil {
+		return w
+	}
+	return io.Discard
+}
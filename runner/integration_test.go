@@ -73,7 +73,7 @@ func testExample(t *testing.T, name string) error {
 	var b strings.Builder
 	prn := printer.New(tasks.Subtree("test").LongestID(), &b, false)
 
-	r, err := runner.New(runType, dir, tasks, "test", prn)
+	r, err := runner.New(runType, dir, tasks, []string{"test"}, prn)
 	if err != nil {
 		return fmt.Errorf("Error running tasks: %s", err)
 	}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithKeepGoing makes a short run continue after a task fails, rather than
+// exiting right away. Tasks that depend on the failed task, directly or
+// not, are canceled and marked [TaskStatusBlocked], but independent tasks
+// keep running. Once nothing is left to run, [Run.Start] returns a
+// [*FailuresError] listing every failure.
+//
+// Long runs always keep going, so WithKeepGoing has no effect on them.
+func WithKeepGoing(keepGoing bool) Option {
+	return func(r *Run) { r.keepGoing = keepGoing }
+}
+
+// A Failure is a task that failed during a run.
+type Failure struct {
+	ID  string
+	Err error
+}
+
+// FailuresError is returned by [Run.Start] when tasks fail in a short run
+// with [WithKeepGoing]. It lists the failures in the order they happened.
+type FailuresError struct {
+	Failures []Failure
+}
+
+func (e *FailuresError) Error() string {
+	var b strings.Builder
+	if len(e.Failures) == 1 {
+		b.WriteString("1 task failed:")
+	} else {
+		fmt.Fprintf(&b, "%d tasks failed:", len(e.Failures))
+	}
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "\n- %s: %s", f.ID, f.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the failed tasks' errors, so that errors.Is can find, for
+// example, [ErrTimedOut].
+func (e *FailuresError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// block cancels every task that depends on the task with the given ID,
+// directly or not, and marks it [TaskStatusBlocked], since it can't run
+// now that that task has failed.
+func (r *Run) block(id string) {
+	var blocked []string
+	seen := map[string]struct{}{}
+	queue := r.tasks.WithDependency(id)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[dep]; ok {
+			continue
+		}
+		seen[dep] = struct{}{}
+		blocked = append(blocked, dep)
+		queue = append(queue, r.tasks.WithDependency(dep)...)
+	}
+
+	for _, dep := range blocked {
+		r.mu.Lock("block")
+		// Stop the executor, but keep it until it exits, so that the
+		// run's cleanup waits for it. handleTaskExit ignores its exit.
+		if exec, ok := r.executors[dep]; ok {
+			go exec.Cancel()
+		}
+		r.taskStatus[dep] = TaskStatusBlocked
+		r.mu.Unlock()
+		r.release(dep)
+		r.printf(dep, logStyle, "blocked: %s failed", id)
+	}
+}
+
+// settled returns true if nothing is left to run in a short run: no short
+// task is running or queued, no long task is still starting up, and no task
+// that hasn't started has all of its dependencies met.
+func (r *Run) settled() bool {
+	for _, id := range r.tasks.IDs() {
+		r.mu.Lock("settled")
+		status := r.taskStatus[id]
+		r.mu.Unlock()
+		isShort := r.tasks.Get(id).Metadata().Type == "short"
+		switch status {
+		case TaskStatusQueued:
+			return false
+		case TaskStatusRunning, TaskStatusUnhealthy:
+			if isShort {
+				return false
+			}
+		case TaskStatusStarting, TaskStatusRestarting:
+			return false
+		case TaskStatusNotStarted:
+			if r.hasAllDeps(id) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"time"
+
+	"monks.co/run/task"
+)
+
+// restartDefaults holds a Run's restart settings for tasks that don't specify
+// their own. Zero values mean the built-in defaults.
+type restartDefaults struct {
+	policy         string
+	maxRestarts    int
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+}
+
+const (
+	defaultBackoffInitial = time.Second
+	defaultBackoffMax     = 30 * time.Second
+)
+
+// WithRestart sets the restart policy for tasks whose [task.TaskMetadata]
+// doesn't specify one: "always", "on-failure", or "never". Since short tasks
+// can't always restart, "always" applies only to long tasks, and short tasks
+// restart on failure. By default, long tasks always restart. [New] returns an
+// error for any other policy.
+func WithRestart(policy string) Option {
+	return func(r *Run) { r.restart.policy = policy }
+}
+
+// WithMaxRestarts sets how many times in a row a failing task is restarted
+// before it is considered crash-looping, for tasks that don't specify
+// MaxRestarts. By default there is no limit.
+func WithMaxRestarts(n int) Option {
+	return func(r *Run) { r.restart.maxRestarts = n }
+}
+
+// WithBackoff sets the delay before restarting a failed task, for tasks that
+// don't specify their own. The delay starts at initial and doubles with each
+// consecutive failure, up to max, which mustn't be less than initial. By
+// default, it starts at 1 second and is capped at 30 seconds, or at the
+// initial delay, if that's longer.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(r *Run) {
+		r.restart.backoffInitial = initial
+		r.restart.backoffMax = max
+	}
+}
+
+// policyFor returns the restart policy that applies to tm.
+func (d restartDefaults) policyFor(tm task.TaskMetadata) string {
+	if tm.Restart != "" {
+		return tm.Restart
+	}
+	if d.policy == "always" && tm.Type != "long" {
+		return "on-failure"
+	}
+	if d.policy != "" {
+		return d.policy
+	}
+	if tm.Type == "long" {
+		return "always"
+	}
+	return "on-failure"
+}
+
+// maxRestartsFor returns the restart limit that applies to tm, or 0 for no
+// limit.
+func (d restartDefaults) maxRestartsFor(tm task.TaskMetadata) int {
+	if tm.MaxRestarts > 0 {
+		return tm.MaxRestarts
+	}
+	return d.maxRestarts
+}
+
+// validate returns an error if the defaults, as set by options, are invalid.
+func (d restartDefaults) validate() error {
+	switch d.policy {
+	case "", "always", "on-failure", "never":
+	default:
+		return fmt.Errorf("Invalid restart policy '%s'; must be 'always', 'on-failure', or 'never'.", d.policy)
+	}
+	if d.maxRestarts < 0 {
+		return errors.New("Max restarts can't be negative.")
+	}
+	if d.backoffInitial < 0 || d.backoffMax < 0 {
+		return errors.New("Backoff can't be negative.")
+	}
+	if d.backoffInitial > 0 && d.backoffMax > 0 && d.backoffMax < d.backoffInitial {
+		return fmt.Errorf("Backoff max %s is less than backoff initial %s.", d.backoffMax, d.backoffInitial)
+	}
+	return nil
+}
+
+// backoffFor returns the delay before restarting tm after its attempts'th
+// consecutive failure.
+func (d restartDefaults) backoffFor(tm task.TaskMetadata, attempts int) time.Duration {
+	initial := d.backoffInitialFor(tm)
+	ceiling := d.backoffMaxFor(tm)
+
+	delay := initial
+	for i := 1; i < attempts && delay < ceiling; i++ {
+		delay *= 2
+	}
+	return min(delay, ceiling)
+}
+
+// backoffInitialFor returns the delay before restarting tm after its first
+// failure.
+func (d restartDefaults) backoffInitialFor(tm task.TaskMetadata) time.Duration {
+	return cmp.Or(tm.BackoffInitial, d.backoffInitial, defaultBackoffInitial)
+}
+
+// backoffMaxFor returns the longest delay before restarting tm. Unless tm
+// sets its own, it's at least tm's initial delay.
+func (d restartDefaults) backoffMaxFor(tm task.TaskMetadata) time.Duration {
+	if tm.BackoffMax > 0 {
+		return tm.BackoffMax
+	}
+	return max(cmp.Or(d.backoffMax, defaultBackoffMax), d.backoffInitialFor(tm))
+}
+
+// restartAfterFailure restarts the long task with the given ID after it
+// failed or became unhealthy, once the backoff for its consecutive failures
+// is up, showing status in the meantime. If it has failed too many times in a
+// row, it is marked crash-looping instead. healthyFor is how long the task
+// was up and healthy before it failed.
+func (r *Run) restartAfterFailure(id string, healthyFor time.Duration, status TaskStatus) {
+	tm := r.tasks.Get(id).Metadata()
+
+	r.mu.Lock("restartAfterFailure")
+	// A task that stayed healthy for longer than its longest backoff has
+	// recovered, so count its failures afresh.
+	if healthyFor > 0 && healthyFor >= r.restart.backoffMaxFor(tm) {
+		r.restartAttempts[id] = 0
+	}
+	r.restartAttempts[id]++
+	attempts := r.restartAttempts[id]
+	r.mu.Unlock()
+
+	if limit := r.restart.maxRestartsFor(tm); limit > 0 && attempts > limit {
+		r.mu.Lock("restartAfterFailure:crashLooping")
+		r.taskStatus[id] = TaskStatusCrashLooping
+		r.mu.Unlock()
+		r.printf(id, logStyle, "crash-looping: giving up after %d restarts", limit)
+		return
+	}
+
+	delay := r.restart.backoffFor(tm, attempts)
+	r.printf(id, logStyle, "retrying in %s", formatDelay(delay))
+	go func() {
+		r.mu.Lock("restartAfterFailure:status")
+		r.taskStatus[id] = status
+		r.mu.Unlock()
+		time.Sleep(delay)
+		r.printf(id, logStyle, "retrying")
+		r.input <- msgRunTask(id)
+	}()
+}
+
+// formatDelay formats a restart delay for display, as in "1 second" or
+// "5 seconds".
+func formatDelay(d time.Duration) string {
+	switch {
+	case d == time.Second:
+		return "1 second"
+	case d%time.Second == 0:
+		return fmt.Sprintf("%d seconds", int(d.Seconds()))
+	default:
+		return d.String()
+	}
+}
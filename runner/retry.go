@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"monks.co/run/task"
+)
+
+// retry schedules another attempt at the short task with the given ID after
+// it fails in a short run, returning true if the task has retries left. If
+// it doesn't, retry returns false, along with the number of attempts the task
+// made.
+func (r *Run) retry(id string) (bool, int) {
+	tm := r.tasks.Get(id).Metadata()
+	r.mu.Lock("retry")
+	r.attempts[id]++
+	failed := r.attempts[id]
+	retrying := failed <= tm.Retries
+	if retrying {
+		r.taskStatus[id] = TaskStatusRestarting
+	} else {
+		delete(r.attempts, id)
+	}
+	r.mu.Unlock()
+	if !retrying {
+		return false, failed
+	}
+
+	if tm.RetryDelay > 0 {
+		r.printf(id, logStyle, "retrying in %s", formatDelay(tm.RetryDelay))
+	}
+	go func() {
+		time.Sleep(tm.RetryDelay)
+		r.input <- msgRunTask(id)
+	}()
+	return true, failed
+}
+
+// attempt returns a description of the task's current attempt, as in
+// "attempt 2 of 3", or "" if it's the task's first attempt.
+func (r *Run) attempt(tm task.TaskMetadata) string {
+	r.mu.Lock("attempt")
+	failed := r.attempts[tm.ID]
+	r.mu.Unlock()
+	if failed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("attempt %d of %d", failed+1, tm.Retries+1)
+}
@@ -5,15 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"charm.land/lipgloss/v2"
+	"monks.co/run/cache"
 	"monks.co/run/internal/executor"
 	"monks.co/run/internal/mutex"
+	"monks.co/run/internal/probe"
 	"monks.co/run/internal/watcher"
 	"monks.co/run/task"
 )
@@ -27,48 +32,76 @@ type (
 		id   string
 		err  error
 		exec *executor.Executor
+
+		fingerprint string // the task's fingerprint as of its start, if it has sources
+		upToDate    bool   // the task was skipped, since its fingerprint was unchanged
+		restored    bool   // the task was skipped, since its outputs were restored from the cache
+		statusOK    bool   // the task was skipped, since its status command passed
+
+		readyAt time.Time // when the task became ready, if it did
 	}
 	msgFSEvent struct {
-		path string
-		evs  []watcher.EventInfo
+		watch  watch
+		evs    []watcher.EventInfo
+		hashes map[string]string // the hashes of the events' files, if a task with the watch has WatchHash
+	}
+	msgTaskUnhealthy struct {
+		id   string
+		err  error
+		exec *executor.Executor
+
+		healthyFor time.Duration // how long the task passed its probe after becoming ready
 	}
 	msgInvalidate string
 	msgAddTasks   []string
 	msgRemoveTask string
 )
 
+// ran returns true if the task actually ran, rather than being skipped.
+func (msg msgTaskExit) ran() bool {
+	return !msg.upToDate && !msg.restored && !msg.statusOK
+}
+
 // InternalTaskInterleaved is the ID used for the interleaved output stream.
 const InternalTaskInterleaved = "@interleaved"
 
 // InternalTaskWatch is the ID used for file-watcher messaging.
 const InternalTaskWatch = "@watch"
 
-// New creates an executable Run from a task library, a root task ID, and a
-// display [MultiWriter].
+// New creates an executable Run from a task library, one or more root task
+// IDs, and a display [MultiWriter].
 //
 // The runType parameter controls the run's lifecycle: [RunTypeShort] exits
-// once the root task succeeds or any task fails; [RunTypeLong] keeps running
+// once every root task succeeds or any task fails; [RunTypeLong] keeps running
 // until the context is canceled, restarting failed tasks with backoff.
 //
 // The out [MultiWriter] receives per-task output writers. Its Writer method
 // is not called until [Run.Start].
 //
-// Optional [Option] values tune presentation; see [WithInteractive].
-func New(runType RunType, dir string, allTasks task.Library, taskID string, out MultiWriter, opts ...Option) (*Run, error) {
+// Optional [Option] values tune presentation, restart behavior, and time
+// limits; see [WithInteractive], [WithRestart], and [WithTimeout].
+func New(runType RunType, dir string, allTasks task.Library, taskIDs []string, out MultiWriter, opts ...Option) (*Run, error) {
 	if err := allTasks.Validate(); err != nil {
 		return nil, err
 	}
 
-	if !allTasks.Has(taskID) {
-		lines := []string{fmt.Sprintf("Task %s not found. Tasks are,", taskID)}
-		for _, id := range allTasks.IDs() {
-			lines = append(lines, " - "+id)
+	if len(taskIDs) == 0 {
+		return nil, errors.New("No task to run.")
+	}
+	requestedTasks := map[string]struct{}{}
+	for _, taskID := range taskIDs {
+		if !allTasks.Has(taskID) {
+			lines := []string{fmt.Sprintf("Task %s not found. Tasks are,", taskID)}
+			for _, id := range allTasks.IDs() {
+				lines = append(lines, " - "+id)
+			}
+			lines = append(lines, "Run `run -list` for more information about the available tasks.")
+			return nil, errors.New(strings.Join(lines, "\n"))
 		}
-		lines = append(lines, "Run `run -list` for more information about the available tasks.")
-		return nil, errors.New(strings.Join(lines, "\n"))
+		requestedTasks[taskID] = struct{}{}
 	}
 
-	tasks := allTasks.Subtree(taskID)
+	tasks := allTasks.Subtree(taskIDs...)
 
 	taskStatus := map[string]TaskStatus{}
 	for _, id := range tasks.IDs() {
@@ -80,10 +113,16 @@ func New(runType RunType, dir string, allTasks task.Library, taskID string, out
 
 		taskStatus:      taskStatus,
 		restartAttempts: map[string]int{},
+		attempts:        map[string]int{},
 		ran:             map[string]struct{}{},
 		executors:       map[string]*executor.Executor{},
 		writers:         map[string]io.Writer{},
-		watches:         map[string]func(){},
+		watches:         map[watch]func(){},
+		slots:           map[string][]string{},
+		resourceUse:     map[string]int{},
+		changedFiles:    map[string][]string{},
+		hashes:          map[string]map[string]string{},
+		watchTriggers:   map[string]*watchTrigger{},
 
 		input: make(chan any, 256),
 
@@ -91,10 +130,10 @@ func New(runType RunType, dir string, allTasks task.Library, taskID string, out
 		allTasks: allTasks,
 		dir:      dir,
 		runType:  runType,
-		rootID:   taskID,
+		rootIDs:  taskIDs,
 
 		tasks:          tasks,
-		requestedTasks: map[string]struct{}{taskID: {}},
+		requestedTasks: requestedTasks,
 
 		interactive: true,
 	}
@@ -103,10 +142,27 @@ func New(runType RunType, dir string, allTasks task.Library, taskID string, out
 		opt(&run)
 	}
 
+	if err := run.restart.validate(); err != nil {
+		return nil, err
+	}
+
+	// On-exit tasks run like hooks, without their dependencies or hooks.
+	for _, id := range run.onExit {
+		if !allTasks.Has(id) {
+			return nil, fmt.Errorf("On-exit task %s not found.", id)
+		} else if tm := allTasks.Get(id).Metadata(); tm.Type != "short" {
+			return nil, fmt.Errorf("On-exit task %s is long. Only short tasks can run on exit.", id)
+		} else if len(tm.Dependencies) > 0 {
+			return nil, fmt.Errorf("On-exit task %s has dependencies. On-exit tasks run without their dependencies, so they can't have any.", id)
+		} else if len(tm.Before)+len(tm.After)+len(tm.Finally) > 0 {
+			return nil, fmt.Errorf("On-exit task %s has hooks. On-exit tasks run without their hooks, so they can't have any.", id)
+		}
+	}
+
 	return &run, nil
 }
 
-// An Option tunes a [Run]'s presentation behavior at construction.
+// An Option tunes a [Run]'s behavior at construction.
 type Option func(*Run)
 
 // WithInteractive controls human-oriented output formatting. It defaults to
@@ -118,6 +174,13 @@ func WithInteractive(interactive bool) Option {
 	return func(r *Run) { r.interactive = interactive }
 }
 
+// WithArgs sets extra arguments for the run's root tasks, as in
+// `run test -- -run TestFoo`. The root tasks receive them through their
+// contexts; see [task.Args].
+func WithArgs(args ...string) Option {
+	return func(r *Run) { r.args = args }
+}
+
 // A Run represents an execution of a task, including,
 //   - execution of other tasks that it depends on
 //   - configuration of file-watches for retriggering tasks.
@@ -129,12 +192,25 @@ type Run struct {
 	// Mutable state, guarded by mu:
 	taskStatus      map[string]TaskStatus
 	restartAttempts map[string]int
+	attempts        map[string]int // failed attempts in a row, for retries in short runs
 	ran             map[string]struct{}
 	executors       map[string]*executor.Executor
 	writers         map[string]io.Writer
-	watches         map[string]func() // active file watchers, keyed by path
-	tasks           task.Library      // active subset of allTasks
+	watches         map[watch]func() // active file watchers
+	tasks           task.Library     // active subset of allTasks
 	requestedTasks  map[string]struct{}
+	slots           map[string][]string // resources held by short tasks with concurrency slots
+	resourceUse     map[string]int      // number of slot holders using each resource
+	queue           []string            // short tasks waiting for a slot, in order
+	failures        []Failure           // failed tasks, with keep-going
+	changedFiles    map[string][]string // changed files for tasks about to rerun because of them
+	// hashes maps the IDs of tasks with WatchHash to the hashes of the
+	// watched files that have triggered them, as of when they did.
+	hashes map[string]map[string]string
+
+	// watchTriggers holds each task's debounce state for reruns due to
+	// changes to paths it watches.
+	watchTriggers map[string]*watchTrigger
 
 	// Single message channel for the event loop.
 	input chan any
@@ -143,9 +219,24 @@ type Run struct {
 	out      MultiWriter
 	allTasks task.Library // full task universe
 	runType     RunType
-	rootID      string
+	rootIDs     []string
 	dir         string
 	interactive bool // apply human-oriented formatting: JSON indenting and colored status lines
+	restart     restartDefaults
+	timeout     time.Duration  // limit on the whole run; 0 means none
+	args        []string       // extra arguments for the root tasks
+	stateDir    string         // where fingerprints are kept; "" means nowhere
+	cache       cache.Cache    // where outputs are kept; nil means nowhere
+	force       bool           // run tasks even if they're up to date
+	concurrency int            // limit on simultaneous short tasks; 0 means none
+	resources   map[string]int // limits on simultaneous users of each resource
+	keepGoing   bool           // in short runs, keep running independent tasks after a failure
+	onExit      []string       // tasks to run once the run is over
+	exitTimeout time.Duration  // limit on each on-exit task; 0 means defaultOnExitTimeout
+	debounce    time.Duration  // default wait for watched changes to stop; 0 means defaultDebounce
+	throttle    time.Duration  // default minimum time between watch-triggered reruns; 0 means none
+	watchMode   string         // how file watchers find changes; see WithWatchMode
+	pollEvery   time.Duration  // how often polling file watchers check for changes
 }
 
 //go:generate go run golang.org/x/tools/cmd/stringer -type TaskStatus
@@ -159,6 +250,12 @@ const (
 	TaskStatusFailed
 	TaskStatusCanceled
 	TaskStatusDone
+	TaskStatusStarting
+	TaskStatusUnhealthy
+	TaskStatusCrashLooping
+	TaskStatusTimedOut
+	TaskStatusQueued
+	TaskStatusBlocked
 )
 
 // MultiWriter is the interface Runs use to display UI. A MultiWriter must
@@ -173,13 +270,17 @@ type MultiWriter interface {
 // IDs returns the list of output stream names that a Run would write to. This
 // includes the IDs of each Task that will be used in the run, plus (if
 // applicable) the id "@watch", which the Run uses for messaging about file
-// watchers.
+// watchers, and the id "@exit", which it uses for the output of its on-exit
+// tasks.
 func (r *Run) IDs() []string {
 	defer r.mu.Lock("IDs").Unlock()
 	var ids []string
 	if len(r.tasks.Watches()) > 0 {
 		ids = append(ids, InternalTaskWatch)
 	}
+	if len(r.onExit) > 0 {
+		ids = append(ids, InternalTaskExit)
+	}
 	return append(ids, r.tasks.IDs()...)
 }
 
@@ -222,8 +323,8 @@ func (r *Run) Start(ctx context.Context) error {
 
 	// Start all the file watchers. Do this before starting tasks so that
 	// tasks can trigger file watcher events.
-	for _, p := range r.tasks.Watches() {
-		if err := r.startWatcher(p); err != nil {
+	for _, w := range watchesOf(r.tasks) {
+		if err := r.startWatcher(w); err != nil {
 			return err
 		}
 	}
@@ -241,6 +342,13 @@ func (r *Run) Start(ctx context.Context) error {
 		r.input <- msgRunTask(id)
 	}
 
+	var deadline <-chan time.Time
+	if r.timeout > 0 {
+		timer := time.NewTimer(r.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
 	// Run the event loop.
 	var loopErr error
 	for loopErr == nil {
@@ -249,6 +357,9 @@ func (r *Run) Start(ctx context.Context) error {
 			loopErr = r.handleMessage(ctx, msg)
 		case <-ctx.Done():
 			loopErr = &runExitError{err: nil}
+		case <-deadline:
+			r.markTimedOut()
+			loopErr = &runExitError{err: fmt.Errorf("run %w after %s", ErrTimedOut, r.timeout)}
 		}
 	}
 
@@ -260,6 +371,9 @@ func (r *Run) Start(ctx context.Context) error {
 	for _, stop := range r.watches {
 		stop()
 	}
+	for id := range r.watchTriggers {
+		r.stopWatchTrigger(id)
+	}
 	r.mu.Unlock()
 
 	for _, id := range r.shutdownOrder() {
@@ -271,10 +385,17 @@ func (r *Run) Start(ctx context.Context) error {
 		}
 	}
 
+	// Once every task has stopped, run the on-exit tasks. Their failures
+	// mustn't mask the run's own.
+	onExitErr := r.runOnExit()
+
 	// Unwrap the sentinel.
 	var exitErr *runExitError
 	if errors.As(loopErr, &exitErr) {
-		return exitErr.err
+		loopErr = exitErr.err
+	}
+	if loopErr == nil {
+		return onExitErr
 	}
 	return loopErr
 }
@@ -350,8 +471,12 @@ func (r *Run) handleMessage(ctx context.Context, msg any) error {
 		r.handleTaskReady(string(msg))
 	case msgTaskExit:
 		return r.handleTaskExit(msg)
+	case msgTaskUnhealthy:
+		r.handleTaskUnhealthy(msg)
 	case msgFSEvent:
 		r.handleFSEvent(msg)
+	case msgWatchTrigger:
+		r.handleWatchTrigger(msg)
 	case msgInvalidate:
 		r.handleInvalidate(string(msg))
 	case msgAddTasks:
@@ -363,27 +488,50 @@ func (r *Run) handleMessage(ctx context.Context, msg any) error {
 }
 
 // handleRunTask cancels any existing executor for the task, creates a new
-// one, and starts the task.
+// one, and starts the task, unless the run's concurrency limits queue it.
 func (r *Run) handleRunTask(ctx context.Context, id string) {
-	// Cancel the old executor synchronously if one exists.
+	// Cancel the old executor synchronously if one exists. A task that's
+	// blocked by a failed dependency mustn't start at all.
 	r.mu.Lock("handleRunTask:read")
 	oldExec := r.executors[id]
+	blocked := r.taskStatus[id] == TaskStatusBlocked
 	r.mu.Unlock()
+	if blocked {
+		return
+	}
 	if oldExec != nil {
 		oldExec.Cancel()
 	}
 
-	r.printf(id, logStyle, "starting")
-
 	t := r.tasks.Get(id)
+	tm := t.Metadata()
+	if !r.acquire(t) {
+		return
+	}
 	exec := executor.New()
 
+	starting := "starting"
+	if attempt := r.attempt(tm); attempt != "" {
+		starting += " (" + attempt + ")"
+	}
+
+	// Fingerprinting sources, restoring outputs, and running status
+	// commands can take a while, so they happen in the executor rather
+	// than here, and we'll know whether the task is starting once they
+	// have.
+	startLater := r.fingerprints(tm) || tm.Status != ""
+	if !startLater {
+		r.printf(id, logStyle, "%s", starting)
+	}
+
 	r.mu.Lock("handleRunTask:write")
 	r.executors[id] = exec
 	w := r.writers[id]
-	if t.Metadata().Type == "short" {
+	if tm.Type == "short" {
 		r.taskStatus[id] = TaskStatusRunning
-	} else if t.Metadata().Type == "long" {
+	} else if tm.Type == "long" && tm.Ready != nil {
+		r.taskStatus[id] = TaskStatusStarting
+	} else if tm.Type == "long" {
 		r.taskStatus[id] = TaskStatusRestarting
 	}
 	r.mu.Unlock()
@@ -393,17 +541,73 @@ func (r *Run) handleRunTask(ctx context.Context, id string) {
 	// task is only canceled when the cleanup loop calls exec.Cancel(),
 	// allowing reverse-dependency shutdown ordering.
 	onReady := make(chan struct{})
-	exec.Execute(context.Background(), func(ctx context.Context) error {
-		return t.Start(ctx, onReady, w)
+	var liveness *probe.Checker
+	if tm.Liveness != nil {
+		liveness = probe.New(*tm.Liveness, r.taskScript(t, ""))
+		w = io.MultiWriter(w, liveness)
+	}
+	execCtx := context.Background()
+	if slices.Contains(r.rootIDs, id) && len(r.args) > 0 {
+		execCtx = task.WithArgs(execCtx, r.args)
+	}
+	if changed := r.takeChangedFiles(id); len(changed) > 0 {
+		execCtx = task.WithChangedFiles(execCtx, changed)
+	}
+	// fp is set in the executor, and read only once it's done.
+	var fp string
+	var upToDate, restored, statusOK atomic.Bool
+	go r.seedHashes(tm)
+	exec.Execute(execCtx, func(ctx context.Context) error {
+		var ok bool
+		if fp, ok = r.checkFingerprint(t); ok {
+			upToDate.Store(true)
+			r.printf(id, logStyle, "up to date")
+			return nil
+		}
+		if r.restoreOutputs(t, fp) {
+			restored.Store(true)
+			r.printf(id, logStyle, "restored outputs from cache")
+			return nil
+		}
+		if tm.Status != "" && r.statusOK(ctx, t) {
+			statusOK.Store(true)
+			r.printf(id, logStyle, "skipped (status ok)")
+			return nil
+		}
+		if startLater {
+			r.printf(id, logStyle, "%s", starting)
+		}
+		err := r.runWithHooks(ctx, t, w, func(ctx context.Context) error {
+			return r.startTask(ctx, t, onReady, w)
+		})
+		// Store the outputs before the task's dependents can start,
+		// and so perhaps change them.
+		if err == nil && fp != "" {
+			r.storeOutputs(t, fp)
+		}
+		return err
 	})
 
+	// Short tasks that run past their timeout are canceled, and exit with
+	// a timeout error rather than context.Canceled.
+	var timedOut atomic.Bool
+	if tm.Type == "short" && tm.Timeout > 0 {
+		go enforceTimeout(exec, tm.Timeout, &timedOut)
+	}
+
 	// Listen for readiness signal or task exit. Status updates happen
 	// in handleTaskReady (inside the event loop) to avoid racing with
 	// handleTaskExit.
+	var readyAt atomic.Pointer[time.Time]
 	go func() {
 		select {
 		case <-onReady:
+			now := time.Now()
+			readyAt.Store(&now)
 			r.input <- msgTaskReady(id)
+			if liveness != nil {
+				r.monitorLiveness(id, exec, liveness, now)
+			}
 		case <-exec.Done():
 			// Task exited before signaling readiness.
 			// If it succeeded, signal readiness so dependents can start.
@@ -416,7 +620,15 @@ func (r *Run) handleRunTask(ctx context.Context, id string) {
 	// Forward the exit event.
 	go func() {
 		<-exec.Done()
-		r.input <- msgTaskExit{id: id, err: exec.Err(), exec: exec}
+		err := exec.Err()
+		if err != nil && timedOut.Load() {
+			err = fmt.Errorf("%w after %s", ErrTimedOut, tm.Timeout)
+		}
+		msg := msgTaskExit{id: id, err: err, exec: exec, fingerprint: fp, upToDate: upToDate.Load(), restored: restored.Load(), statusOK: statusOK.Load()}
+		if t := readyAt.Load(); t != nil {
+			msg.readyAt = *t
+		}
+		r.input <- msg
 	}()
 }
 
@@ -424,11 +636,12 @@ func (r *Run) handleRunTask(ctx context.Context, id string) {
 func (r *Run) handleTaskReady(id string) {
 	r.mu.Lock("handleTaskReady")
 	r.ran[id] = struct{}{}
-	// Promote Restarting → Running for long tasks that have signaled
-	// readiness. Don't overwrite terminal states (Done, Failed) — a
-	// late-arriving msgTaskReady must not clobber a status that
+	// Promote Restarting or Starting → Running for long tasks that have
+	// signaled readiness. Don't overwrite terminal states (Done, Failed) —
+	// a late-arriving msgTaskReady must not clobber a status that
 	// handleTaskExit already set.
-	if r.taskStatus[id] == TaskStatusRestarting {
+	switch r.taskStatus[id] {
+	case TaskStatusRestarting, TaskStatusStarting:
 		r.taskStatus[id] = TaskStatusRunning
 	}
 	r.mu.Unlock()
@@ -478,74 +691,96 @@ func (r *Run) handleTaskExit(msg msgTaskExit) error {
 	// - the current executor is different from the one that exited.
 	r.mu.Lock("handleTaskExit:stale")
 	currentExec := r.executors[msg.id]
+	stale := currentExec == nil || !currentExec.Is(msg.exec)
+	// A blocked task's executor was stopped by block, which already
+	// released its resources; all that's left is to forget it.
+	blocked := !stale && r.taskStatus[msg.id] == TaskStatusBlocked
+	if blocked {
+		delete(r.executors, msg.id)
+	}
 	r.mu.Unlock()
-	if currentExec == nil || !currentExec.Is(msg.exec) {
+	if stale || blocked {
 		return nil
 	}
+	r.release(msg.id)
 
-	if msg.err != nil {
+	if errors.Is(msg.err, ErrTimedOut) {
+		r.printf(msg.id, logStyle, "%s", msg.err)
+		r.mu.Lock("handleTaskExit:timedOut")
+		r.taskStatus[msg.id] = TaskStatusTimedOut
+		r.mu.Unlock()
+	} else if msg.err != nil {
 		r.printf(msg.id, logStyle, "exit: %s", msg.err)
 		r.mu.Lock("handleTaskExit:failed")
 		r.taskStatus[msg.id] = TaskStatusFailed
 		r.mu.Unlock()
 	} else {
+		attempt := r.attempt(r.tasks.Get(msg.id).Metadata())
 		r.mu.Lock("handleTaskExit:done")
 		r.taskStatus[msg.id] = TaskStatusDone
 		r.ran[msg.id] = struct{}{}
+		delete(r.attempts, msg.id)
 		r.mu.Unlock()
-		r.printf(msg.id, logStyle, "exit ok")
-	}
-
-	if r.runType == RunTypeShort {
-		// In short runs, exit when the root task does, or when any
-		// task fails.
-		if r.rootID == msg.id || msg.err != nil {
-			// Even though the run is over, it's important to
-			// update the task statuses. The UI might remain open,
-			// and should display each task's final status.
-			r.mu.Lock("handleTaskExit:shortEnd")
-			for k, s := range r.taskStatus {
-				switch s {
-				case TaskStatusRunning, TaskStatusRestarting:
-					r.taskStatus[k] = TaskStatusCanceled
-				}
-			}
-			r.mu.Unlock()
-			return &runExitError{err: msg.err}
+		if msg.ran() && attempt != "" {
+			r.printf(msg.id, logStyle, "exit ok (%s)", attempt)
+		} else if msg.ran() {
+			r.printf(msg.id, logStyle, "exit ok")
+		}
+		if msg.fingerprint != "" && (msg.ran() || msg.restored) {
+			r.recordFingerprint(msg.id, msg.fingerprint)
+		}
+	}
+
+	// In short runs, retry failed tasks that have retries left before
+	// treating their failures as final.
+	if r.runType == RunTypeShort && msg.err != nil {
+		if retrying, attempts := r.retry(msg.id); retrying {
+			return nil
+		} else if attempts > 1 {
+			msg.err = fmt.Errorf("%w (after %d attempts)", msg.err, attempts)
+		}
+	}
+
+	if r.runType == RunTypeShort && r.keepGoing {
+		// With keep-going, a failure only blocks the failed task's
+		// dependents. Exit once there's nothing left to run.
+		if msg.err != nil {
+			r.failures = append(r.failures, Failure{ID: msg.id, Err: msg.err})
+			r.block(msg.id)
+		}
+		if len(r.failures) > 0 && (r.rootsDone() || r.settled()) {
+			return r.endShortRun(&FailuresError{Failures: r.failures})
+		} else if msg.err != nil {
+			return nil
+		} else if r.rootsDone() {
+			return r.endShortRun(nil)
+		}
+	} else if r.runType == RunTypeShort {
+		// In short runs, exit once every root task has succeeded, or
+		// when any task fails.
+		if msg.err != nil || r.rootsDone() {
+			return r.endShortRun(msg.err)
 		}
 	}
 
 	t := r.tasks.Get(msg.id)
 	tm := t.Metadata()
 
+	policy := r.restart.policyFor(tm)
+
 	// If the run is "long" and the task exit was unexpected, retry
 	// with exponential backoff.
-	if r.runType == RunTypeLong && msg.err != nil {
-		r.mu.Lock("handleTaskExit:backoff")
-		r.restartAttempts[msg.id]++
-		attempts := r.restartAttempts[msg.id]
-		r.mu.Unlock()
-
-		delay := min(time.Second*(1<<(attempts-1)), 30*time.Second)
-		delaySec := int(delay.Seconds())
-		if delaySec == 1 {
-			r.printf(msg.id, logStyle, "retrying in 1 second")
-		} else {
-			r.printf(msg.id, logStyle, "retrying in %d seconds", delaySec)
-		}
-		go func() {
-			r.mu.Lock("handleTaskExit:backoff:status")
-			r.taskStatus[msg.id] = TaskStatusRestarting
-			r.mu.Unlock()
-			time.Sleep(delay)
-			r.printf(msg.id, logStyle, "retrying")
-			r.input <- msgRunTask(msg.id)
-		}()
+	if r.runType == RunTypeLong && msg.err != nil && policy != "never" {
+		var healthyFor time.Duration
+		if !msg.readyAt.IsZero() {
+			healthyFor = time.Since(msg.readyAt)
+		}
+		r.restartAfterFailure(msg.id, healthyFor, TaskStatusRestarting)
 		return nil
 	}
 
 	// If the task is "long", retry as a keepalive.
-	if tm.Type == "long" {
+	if tm.Type == "long" && policy == "always" {
 		r.mu.Lock("handleTaskExit:keepalive")
 		r.restartAttempts[msg.id] = 0
 		r.taskStatus[msg.id] = TaskStatusRestarting
@@ -556,29 +791,69 @@ func (r *Run) handleTaskExit(msg msgTaskExit) error {
 	return nil
 }
 
-// handleFSEvent processes a file system event and restarts affected tasks.
+// endShortRun returns the error that ends a short run with err.
+func (r *Run) endShortRun(err error) error {
+	// Even though the run is over, it's important to update the task
+	// statuses. The UI might remain open, and should display each task's
+	// final status.
+	r.mu.Lock("endShortRun")
+	for k, s := range r.taskStatus {
+		switch s {
+		case TaskStatusRunning, TaskStatusRestarting, TaskStatusStarting, TaskStatusUnhealthy, TaskStatusQueued:
+			r.taskStatus[k] = TaskStatusCanceled
+		}
+	}
+	r.mu.Unlock()
+	return &runExitError{err: err}
+}
+
+// handleTaskUnhealthy marks a task whose liveness probe failed as unhealthy
+// and, unless its restart policy is "never", restarts it as though it had
+// failed.
+func (r *Run) handleTaskUnhealthy(msg msgTaskUnhealthy) {
+	r.mu.Lock("handleTaskUnhealthy")
+	currentExec := r.executors[msg.id]
+	stale := currentExec == nil || !currentExec.Is(msg.exec) || r.taskStatus[msg.id] != TaskStatusRunning
+	if !stale {
+		r.taskStatus[msg.id] = TaskStatusUnhealthy
+	}
+	r.mu.Unlock()
+	if stale {
+		return
+	}
+
+	r.printf(msg.id, logStyle, "unhealthy: %s", msg.err)
+	if r.restart.policyFor(r.tasks.Get(msg.id).Metadata()) != "never" {
+		// The task keeps running, unhealthy, until it restarts.
+		r.restartAfterFailure(msg.id, msg.healthyFor, TaskStatusUnhealthy)
+	}
+}
+
+// handleFSEvent processes a file system event and schedules reruns of the
+// affected tasks, once each task's debounce is up.
 func (r *Run) handleFSEvent(msg msgFSEvent) {
 	r.printf(InternalTaskWatch, logStyle, "%s", printFSEvent(msg))
 
-	invalidations := map[string]struct{}{}
-	for _, id := range r.tasks.WithWatch(msg.path) {
-		if r.hasAllDeps(id) {
-			invalidations[id] = struct{}{}
+	for _, id := range withWatch(r.tasks, msg.watch) {
+		if !r.hasAllDeps(id) {
+			continue
+		}
+		if evs := r.triggeringEvents(r.tasks.Get(id).Metadata(), msg.evs, msg.hashes); len(evs) > 0 {
+			r.addChangedFiles(id, evs)
+			r.scheduleWatchRun(id)
 		}
 	}
-	if len(invalidations) > 0 {
-		var ids []string
-		for id := range invalidations {
-			ids = append(ids, id)
-		}
-		r.printf(InternalTaskWatch, logStyle, "invalidating {%s}", strings.Join(ids, ", "))
-		for _, id := range ids {
-			r.mu.Lock("handleFSEvent:resetBackoff")
-			r.restartAttempts[id] = 0
-			r.mu.Unlock()
-			r.input <- msgRunTask(id)
+}
+
+// rootsDone returns true if every root task has run to completion.
+func (r *Run) rootsDone() bool {
+	defer r.mu.Lock("rootsDone").Unlock()
+	for _, id := range r.rootIDs {
+		if r.taskStatus[id] != TaskStatusDone {
+			return false
 		}
 	}
+	return true
 }
 
 // handleInvalidate resets backoff and restarts a task.
@@ -589,7 +864,7 @@ func (r *Run) handleInvalidate(id string) {
 	r.mu.Unlock()
 
 	switch status {
-	case TaskStatusRunning, TaskStatusDone, TaskStatusFailed, TaskStatusCanceled:
+	case TaskStatusRunning, TaskStatusStarting, TaskStatusUnhealthy, TaskStatusDone, TaskStatusFailed, TaskStatusCanceled, TaskStatusCrashLooping, TaskStatusTimedOut:
 		r.input <- msgRunTask(id)
 	}
 }
@@ -629,17 +904,17 @@ func (r *Run) handleAddTasks(ctx context.Context, ids []string) {
 	}
 	r.mu.Unlock()
 
-	// Start new file watchers for any watch paths that don't have one yet.
+	// Start new file watchers for any watches that don't have one yet.
 	r.mu.Lock("handleAddTasks:watches")
-	var newWatchPaths []string
-	for _, p := range newTasks.Watches() {
-		if _, exists := r.watches[p]; !exists {
-			newWatchPaths = append(newWatchPaths, p)
+	var newWatches []watch
+	for _, w := range watchesOf(newTasks) {
+		if _, exists := r.watches[w]; !exists {
+			newWatches = append(newWatches, w)
 		}
 	}
 	r.mu.Unlock()
-	for _, p := range newWatchPaths {
-		r.startWatcher(p)
+	for _, w := range newWatches {
+		r.startWatcher(w)
 	}
 
 	// Ensure the @watch writer exists if we now have watches.
@@ -708,41 +983,75 @@ func (r *Run) handleRemoveTask(id string) {
 		// Clean up status maps.
 		delete(r.taskStatus, rid)
 		delete(r.restartAttempts, rid)
+		delete(r.attempts, rid)
+		delete(r.changedFiles, rid)
+		delete(r.hashes, rid)
+		r.stopWatchTrigger(rid)
 		delete(r.ran, rid)
 		delete(r.writers, rid)
 	}
 
-	// Stop watchers for paths no longer watched.
-	oldWatches := map[string]struct{}{}
-	for _, p := range oldTasks.Watches() {
-		oldWatches[p] = struct{}{}
-	}
-	for p := range oldWatches {
-		if !newTasks.HasWatch(p) {
-			if stop, ok := r.watches[p]; ok {
+	// Stop watchers that are no longer needed.
+	stillWatched := watchesOf(newTasks)
+	for _, w := range watchesOf(oldTasks) {
+		if !slices.Contains(stillWatched, w) {
+			if stop, ok := r.watches[w]; ok {
 				stop()
-				delete(r.watches, p)
+				delete(r.watches, w)
 			}
 		}
 	}
 	r.mu.Unlock()
+
+	// Free the removed tasks' concurrency slots for any queued tasks.
+	for _, rid := range toRemove {
+		r.release(rid)
+	}
 }
 
-// startWatcher starts a file watcher for the given path and stores it in
+// startWatcher starts a file watcher for the given watch and stores it in
 // r.watches.
-func (r *Run) startWatcher(p string) error {
-	watchP := filepath.Join(r.dir, p)
-	r.printf(InternalTaskWatch, logStyle, "watching %s", watchP)
-	c, stop, err := watcher.Watch(watchP)
+func (r *Run) startWatcher(w watch) error {
+	dir := watchDir(r.dir)
+	watchP := filepath.Join(dir, w.path)
+	opts := w.options(dir)
+	opts.Mode = r.watchMode
+	opts.PollInterval = r.pollEvery
+	opts.Notice = func(msg string) { r.printf(InternalTaskWatch, logStyle, "%s", msg) }
+	r.printf(InternalTaskWatch, logStyle, "watching %s%s", watchP, describeOptions(opts))
+	keep := func(watcher.EventInfo) bool { return true }
+	c, stop, err := watcher.Watch(watchP, opts)
+	if _, statErr := os.Stat(watchP); err != nil && errors.Is(statErr, fs.ErrNotExist) && !strings.Contains(watchP, "*") {
+		// A file that doesn't exist yet, like a missing env file, can't
+		// be watched, so watch its directory for it instead.
+		file := filepath.Clean(watchP)
+		keep = func(ev watcher.EventInfo) bool { return ev.Path == file }
+		c, stop, err = watcher.Watch(filepath.Dir(file), opts)
+	}
 	if err != nil {
 		return err
 	}
 	r.mu.Lock("startWatcher")
-	r.watches[p] = stop
+	r.watches[w] = stop
 	r.mu.Unlock()
+	hashed := slices.ContainsFunc(withWatch(r.allTasks, w), func(id string) bool {
+		return r.allTasks.Get(id).Metadata().WatchHash
+	})
 	go func() {
 		for evs := range c {
-			r.input <- msgFSEvent{path: p, evs: evs}
+			evs = slices.DeleteFunc(evs, func(ev watcher.EventInfo) bool { return !keep(ev) })
+			if len(evs) == 0 {
+				continue
+			}
+			msg := msgFSEvent{watch: w, evs: evs}
+			if hashed {
+				var paths []string
+				for _, ev := range evs {
+					paths = append(paths, ev.Path)
+				}
+				msg.hashes = hashFiles(paths)
+			}
+			r.input <- msg
 		}
 	}()
 	return nil
@@ -775,6 +1084,32 @@ func (r *Run) printf(id string, style lipgloss.Style, f string, args ...any) {
 	w.Write([]byte(s + "\n"))
 }
 
+// addChangedFiles adds the paths in evs to the changed files for the task
+// with the given ID's next run. Paths accumulate until the task starts, so
+// a task that's queued gets every change since it last ran.
+func (r *Run) addChangedFiles(id string, evs []watcher.EventInfo) {
+	defer r.mu.Lock("addChangedFiles").Unlock()
+	for _, ev := range evs {
+		p, err := filepath.Abs(ev.Path)
+		if err != nil {
+			p = ev.Path
+		}
+		if !slices.Contains(r.changedFiles[id], p) {
+			r.changedFiles[id] = append(r.changedFiles[id], p)
+		}
+	}
+}
+
+// takeChangedFiles returns the changed files for the task with the given
+// ID's next run, in sorted order, and forgets them.
+func (r *Run) takeChangedFiles(id string) []string {
+	defer r.mu.Lock("takeChangedFiles").Unlock()
+	files := r.changedFiles[id]
+	delete(r.changedFiles, id)
+	slices.Sort(files)
+	return files
+}
+
 func printFSEvent(e msgFSEvent) string {
 	var b strings.Builder
 	b.WriteString("watched file changes:\n")
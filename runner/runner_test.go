@@ -1,18 +1,23 @@
 package runner_test
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"testing/synctest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"monks.co/run/cache"
 	"monks.co/run/internal/fixtures"
 	"monks.co/run/internal/watcher"
 	"monks.co/run/runner"
@@ -34,7 +39,8 @@ func startRun(t *testing.T, tasks []task.Task, rootID string, mw runner.MultiWri
 	t.Helper()
 
 	lib := task.NewLibrary(tasks...)
-	r, err := runner.New(runTypeFor(lib, rootID), ".", lib, rootID, mw)
+	// Events from the mock watcher needn't settle, so don't wait for them to.
+	r, err := runner.New(runTypeFor(lib, rootID), ".", lib, []string{rootID}, mw, runner.WithDebounce(time.Nanosecond))
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
@@ -53,7 +59,8 @@ func startRunWithHandle(t *testing.T, tasks []task.Task, rootID string, mw runne
 	t.Helper()
 
 	lib := task.NewLibrary(tasks...)
-	r, err := runner.New(runTypeFor(lib, rootID), ".", lib, rootID, mw)
+	// Events from the mock watcher needn't settle, so don't wait for them to.
+	r, err := runner.New(runTypeFor(lib, rootID), ".", lib, []string{rootID}, mw, runner.WithDebounce(time.Nanosecond))
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
@@ -169,7 +176,7 @@ func TestShortRunCanceledStatus(t *testing.T) {
 	t3 := fixtures.NewTask("root", "short").WithDependencies("fast-fail", "slow")
 
 	lib := task.NewLibrary(t1, t2, t3)
-	r, err := runner.New(runner.RunTypeShort, ".", lib, "root", mw)
+	r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"root"}, mw)
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
@@ -687,3 +694,1499 @@ func TestWatchEventRespectsUnmetDependencies(t *testing.T) {
 	cancel()
 	waitFor(t, errs, 5*time.Second)
 }
+
+// --- Test 17: Readiness probe gates dependents ---
+
+func TestReadinessProbeGatesDependents(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		listening := make(chan struct{})
+		db := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			// The process is up, but it isn't accepting connections yet.
+			close(onReady)
+			select {
+			case <-listening:
+				fmt.Fprintln(w, "listening on :5432")
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{
+			ID:    "db",
+			Type:  "long",
+			Ready: &task.Probe{Log: "listening on", Interval: 100 * time.Millisecond},
+		})
+		api := fixtures.NewTask("api", "short").WithDependencies("db")
+
+		r, cancel, errs := startRunWithHandle(t, []task.Task{db, api}, "api", mw)
+		defer cancel()
+
+		synctest.Wait()
+		assert.Equal(t, runner.TaskStatusStarting, r.TaskStatus("db"))
+		assert.NotContains(t, mw.String("api"), "! api:")
+
+		close(listening)
+		err := waitFor(t, errs, 5*time.Second)
+		assert.NoError(t, err)
+		assert.Contains(t, mw.String("db"), "ready")
+		assert.Contains(t, mw.String("api"), "! api: execute")
+	})
+}
+
+// --- Test 18: Readiness probe timeout fails the task ---
+
+func TestReadinessProbeTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		db := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			close(onReady)
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{
+			ID:    "db",
+			Type:  "long",
+			Ready: &task.Probe{Log: "listening on", Interval: 100 * time.Millisecond, Timeout: time.Second},
+		})
+		api := fixtures.NewTask("api", "short").WithDependencies("db")
+
+		lib := task.NewLibrary(db, api)
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"api"}, mw)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		err = r.Start(t.Context())
+		assert.ErrorContains(t, err, "not ready after 1s")
+		assert.Equal(t, runner.TaskStatusFailed, r.TaskStatus("db"))
+		assert.NotContains(t, mw.String("api"), "! api:")
+	})
+}
+
+// --- Test 19: Failing liveness probe restarts the task ---
+
+func TestLivenessProbeRestartsTask(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		var starts atomic.Int32
+		server := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			close(onReady)
+			if starts.Add(1) == 1 {
+				// The first execution hangs without logging heartbeats.
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(50 * time.Millisecond):
+					fmt.Fprintln(w, "tick")
+				}
+			}
+		}, task.TaskMetadata{
+			ID:       "server",
+			Type:     "long",
+			Liveness: &task.Probe{Log: "tick", Interval: 100 * time.Millisecond, FailureThreshold: 2},
+		})
+
+		r, cancel, errs := startRunWithHandle(t, []task.Task{server}, "server", mw)
+
+		// The task stays unhealthy until its backoff is up.
+		time.Sleep(500 * time.Millisecond)
+		synctest.Wait()
+		assert.Equal(t, int32(1), starts.Load())
+		assert.Equal(t, runner.TaskStatusUnhealthy, r.TaskStatus("server"))
+		assert.Contains(t, mw.String("server"), "unhealthy: failed 2 checks in a row")
+		assert.Contains(t, mw.String("server"), "retrying in 1 second")
+
+		time.Sleep(time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(2), starts.Load())
+		assert.Equal(t, runner.TaskStatusRunning, r.TaskStatus("server"))
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+	})
+}
+
+// --- Test 20: Exceeding max restarts marks the task crash-looping ---
+
+func TestMaxRestartsCrashLooping(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		var starts atomic.Int32
+		server := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			starts.Add(1)
+			close(onReady)
+			return errors.New("boom")
+		}, task.TaskMetadata{
+			ID:             "server",
+			Type:           "long",
+			MaxRestarts:    2,
+			BackoffInitial: 100 * time.Millisecond,
+			BackoffMax:     150 * time.Millisecond,
+		})
+
+		r, cancel, errs := startRunWithHandle(t, []task.Task{server}, "server", mw)
+
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(3), starts.Load())
+		assert.Equal(t, runner.TaskStatusCrashLooping, r.TaskStatus("server"))
+		assert.Contains(t, mw.String("server"), "retrying in 100ms")
+		assert.Contains(t, mw.String("server"), "retrying in 150ms")
+		assert.Contains(t, mw.String("server"), "crash-looping: giving up after 2 restarts")
+
+		// Invalidating the task gives it a fresh set of restarts.
+		r.Invalidate("server")
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(6), starts.Load())
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+	})
+}
+
+// --- Test 21: Restart policy applies to clean exits and run-level defaults ---
+
+func TestRestartPolicy(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		var cleanStarts, failingStarts atomic.Int32
+		clean := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			cleanStarts.Add(1)
+			close(onReady)
+			return nil
+		}, task.TaskMetadata{ID: "clean", Type: "long", Restart: "on-failure"})
+		failing := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			failingStarts.Add(1)
+			close(onReady)
+			return errors.New("boom")
+		}, task.TaskMetadata{ID: "failing", Type: "long"})
+		root := fixtures.NewTask("root", "long").
+			WithCancel(context.Canceled).
+			WithDependencies("clean", "failing")
+
+		lib := task.NewLibrary(clean, failing, root)
+		r, err := runner.New(runner.RunTypeLong, ".", lib, []string{"root"}, mw, runner.WithRestart("never"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() { errs <- r.Start(ctx) }()
+
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(1), cleanStarts.Load())
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("clean"))
+		assert.Equal(t, int32(1), failingStarts.Load())
+		assert.Equal(t, runner.TaskStatusFailed, r.TaskStatus("failing"))
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+	})
+}
+
+// --- Test 22: Short task timeout cancels the task ---
+
+func TestShortTaskTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		hung := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{ID: "test", Type: "short", Timeout: time.Minute})
+		sibling := fixtures.NewTask("lint", "short").WithCancel(context.Canceled)
+		root := fixtures.NewTask("ci", "short").WithDependencies("test", "lint")
+
+		lib := task.NewLibrary(hung, sibling, root)
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"ci"}, mw)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		err = r.Start(t.Context())
+		assert.ErrorIs(t, err, runner.ErrTimedOut)
+		assert.EqualError(t, err, "timed out after 1m0s")
+		assert.Equal(t, runner.TaskStatusTimedOut, r.TaskStatus("test"))
+		assert.Equal(t, runner.TaskStatusCanceled, r.TaskStatus("lint"))
+		assert.Contains(t, mw.String("test"), "timed out after 1m0s")
+	})
+}
+
+// --- Test 23: Run timeout stops the whole run ---
+
+func TestRunTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		server := fixtures.NewTask("server", "long").WithCancel(context.Canceled)
+
+		lib := task.NewLibrary(server)
+		r, err := runner.New(runner.RunTypeLong, ".", lib, []string{"server"}, mw, runner.WithTimeout(time.Hour))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		start := time.Now()
+		err = r.Start(t.Context())
+		assert.ErrorIs(t, err, runner.ErrTimedOut)
+		assert.Equal(t, time.Hour, time.Since(start))
+		assert.Equal(t, runner.TaskStatusTimedOut, r.TaskStatus("server"))
+	})
+}
+
+// --- Test 24: Extra arguments go to the root task only ---
+
+func TestArgs(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			args = map[string][]string{}
+		)
+		makeTask := func(id string, deps ...string) task.Task {
+			return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				mu.Lock()
+				args[id] = task.Args(ctx)
+				mu.Unlock()
+				return nil
+			}, task.TaskMetadata{
+				ID:           id,
+				Type:         "short",
+				Dependencies: deps,
+			})
+		}
+
+		lib := task.NewLibrary(makeTask("build"), makeTask("test", "build"))
+		mw := fixtures.NewWriter()
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"test"}, mw, runner.WithArgs("-run", "TestFoo"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+
+		assert.Equal(t, map[string][]string{
+			"build": nil,
+			"test":  {"-run", "TestFoo"},
+		}, args)
+	})
+}
+
+// --- Test 25: A short run with several roots exits once all succeed ---
+
+func TestMultipleRoots(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		makeTask := func(id string, d time.Duration) task.Task {
+			return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				time.Sleep(d)
+				return nil
+			}, task.TaskMetadata{ID: id, Type: "short"})
+		}
+
+		lib := task.NewLibrary(makeTask("api", time.Second), makeTask("web", time.Minute), makeTask("docs", 0))
+		mw := fixtures.NewWriter()
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"api", "web"}, mw)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.Equal(t, []string{"api", "web"}, r.IDs())
+
+		start := time.Now()
+		assert.NoError(t, r.Start(t.Context()))
+		assert.Equal(t, time.Minute, time.Since(start))
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("api"))
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("web"))
+	})
+}
+
+// --- Test 26: Short tasks with unchanged sources are skipped ---
+
+func TestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "schema.graphql"), []byte("type A"), 0o644))
+
+	var runs int
+	codegen := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs++
+		return os.WriteFile(filepath.Join(dir, "gen.go"), []byte("package gen"), 0o644)
+	}, task.TaskMetadata{
+		ID:      "codegen",
+		Type:    "short",
+		Sources: []string{"*.graphql"},
+		Outputs: []string{"gen.go"},
+	})
+	lib := task.NewLibrary(codegen)
+
+	run := func(opts ...runner.Option) *fixtures.Writer {
+		t.Helper()
+		mw := fixtures.NewWriter()
+		opts = append(opts, runner.WithStateDir(stateDir))
+		r, err := runner.New(runner.RunTypeShort, dir, lib, []string{"codegen"}, mw, opts...)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("codegen"))
+		return mw
+	}
+
+	run()
+	assert.Equal(t, 1, runs)
+
+	mw := run()
+	assert.Equal(t, 1, runs)
+	assert.Contains(t, mw.String("codegen"), "up to date")
+	assert.NotContains(t, mw.String("codegen"), "starting")
+
+	// Changing a source makes the task run again.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "schema.graphql"), []byte("type B"), 0o644))
+	run()
+	assert.Equal(t, 2, runs)
+
+	// So does a missing output.
+	assert.NoError(t, os.Remove(filepath.Join(dir, "gen.go")))
+	run()
+	assert.Equal(t, 3, runs)
+
+	// And so does forcing it.
+	run(runner.WithForce(true))
+	assert.Equal(t, 4, runs)
+	run()
+	assert.Equal(t, 4, runs)
+}
+
+// --- Test 27: Cached outputs are restored instead of rerunning ---
+
+func TestCacheRestore(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.NewDir(t.TempDir())
+	schema := filepath.Join(dir, "schema.graphql")
+	gen := filepath.Join(dir, "gen", "schema.go")
+
+	var runs int
+	codegen := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs++
+		b, err := os.ReadFile(schema)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(gen), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(gen, append([]byte("// generated from "), b...), 0o644)
+	}, task.TaskMetadata{
+		ID:      "codegen",
+		Type:    "short",
+		Sources: []string{"schema.graphql"},
+		Outputs: []string{"gen/*.go"},
+	})
+	lib := task.NewLibrary(codegen)
+
+	run := func(source string) *fixtures.Writer {
+		t.Helper()
+		assert.NoError(t, os.WriteFile(schema, []byte(source), 0o644))
+		mw := fixtures.NewWriter()
+		r, err := runner.New(runner.RunTypeShort, dir, lib, []string{"codegen"}, mw, runner.WithCache(c))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+		b, err := os.ReadFile(gen)
+		assert.NoError(t, err)
+		assert.Equal(t, "// generated from "+source, string(b))
+		return mw
+	}
+
+	// Switching back and forth only runs the task once per source.
+	run("type A")
+	run("type B")
+	assert.Equal(t, 2, runs)
+	mw := run("type A")
+	assert.Equal(t, 2, runs)
+	assert.Contains(t, mw.String("codegen"), "restored outputs from cache")
+	assert.NotContains(t, mw.String("codegen"), "starting")
+	run("type B")
+	assert.Equal(t, 2, runs)
+
+	// Restoring replaces outputs that were deleted.
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, "gen")))
+	run("type B")
+	assert.Equal(t, 2, runs)
+}
+
+// --- Test 28: A passing status command skips the task ---
+
+func TestStatus(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		migrate := fixtures.NewTask("migrate", "short").WithStatus("true")
+		seed := fixtures.NewTask("seed", "short").WithStatus("false")
+		dev := fixtures.NewTask("dev", "short").WithDependencies("migrate", "seed")
+
+		mw := fixtures.NewWriter()
+		lib := task.NewLibrary(migrate, seed, dev)
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"dev"}, mw)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+
+		assert.Contains(t, mw.String("migrate"), "skipped (status ok)")
+		assert.NotContains(t, mw.String("migrate"), "! migrate: execute")
+		assert.NotContains(t, mw.String("migrate"), "exit ok")
+		assert.Contains(t, mw.String("seed"), "! seed: execute")
+		assert.Contains(t, mw.String("dev"), "! dev: execute")
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("migrate"))
+	})
+}
+
+// --- Test 29: Concurrency and resource limits queue short tasks ---
+
+func TestConcurrency(t *testing.T) {
+	makeLib := func(resources map[string][]string) task.Library {
+		var tasks []task.Task
+		for _, id := range []string{"a", "b", "c", "d"} {
+			tasks = append(tasks, task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				time.Sleep(time.Minute)
+				return nil
+			}, task.TaskMetadata{ID: id, Type: "short", Resources: resources[id]}))
+		}
+		tasks = append(tasks, task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			return nil
+		}, task.TaskMetadata{ID: "all", Type: "short", Dependencies: []string{"a", "b", "c", "d"}}))
+		return task.NewLibrary(tasks...)
+	}
+	countStatus := func(r *runner.Run, status runner.TaskStatus) int {
+		n := 0
+		for _, id := range []string{"a", "b", "c", "d"} {
+			if r.TaskStatus(id) == status {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("concurrency", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			r, err := runner.New(runner.RunTypeShort, ".", makeLib(nil), []string{"all"}, fixtures.NewWriter(), runner.WithConcurrency(2))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			start := time.Now()
+			errs := make(chan error, 1)
+			go func() { errs <- r.Start(t.Context()) }()
+
+			time.Sleep(30 * time.Second)
+			synctest.Wait()
+			assert.Equal(t, 2, countStatus(r, runner.TaskStatusRunning))
+			assert.Equal(t, 2, countStatus(r, runner.TaskStatusQueued))
+
+			assert.NoError(t, <-errs)
+			assert.Equal(t, 2*time.Minute, time.Since(start))
+			assert.Equal(t, 4, countStatus(r, runner.TaskStatusDone))
+		})
+	})
+
+	t.Run("resources", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			lib := makeLib(map[string][]string{
+				"a": {"db"},
+				"b": {"db"},
+				"c": {"db", "port"},
+				"d": {"port"},
+			})
+			r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"all"}, fixtures.NewWriter(), runner.WithResources(map[string]int{"db": 2}))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			start := time.Now()
+			errs := make(chan error, 1)
+			go func() { errs <- r.Start(t.Context()) }()
+
+			// a and b hold both db slots, and d holds the only port
+			// slot, so c waits for both.
+			time.Sleep(30 * time.Second)
+			synctest.Wait()
+			assert.Equal(t, runner.TaskStatusQueued, r.TaskStatus("c"))
+			assert.Equal(t, 3, countStatus(r, runner.TaskStatusRunning))
+
+			assert.NoError(t, <-errs)
+			assert.Equal(t, 2*time.Minute, time.Since(start))
+		})
+	})
+}
+
+// --- Test 30: Keep-going runs independent tasks after a failure ---
+
+func TestKeepGoing(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		makeTask := func(id string, d time.Duration, err error, deps ...string) task.Task {
+			return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				time.Sleep(d)
+				return err
+			}, task.TaskMetadata{ID: id, Type: "short", Dependencies: deps})
+		}
+		lib := task.NewLibrary(
+			makeTask("lint", 0, errors.New("lint failed")),
+			makeTask("vet", 2*time.Minute, errors.New("vet failed")),
+			makeTask("test", time.Minute, nil),
+			makeTask("pkg", 0, nil, "lint"),
+			makeTask("ci", 0, nil, "vet", "test", "pkg"),
+		)
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"ci"}, fixtures.NewWriter(), runner.WithKeepGoing(true))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		start := time.Now()
+		err = r.Start(t.Context())
+		assert.Equal(t, 2*time.Minute, time.Since(start))
+
+		var failures *runner.FailuresError
+		if !errors.As(err, &failures) {
+			t.Fatalf("expected a FailuresError, got %v", err)
+		}
+		assert.Equal(t, "2 tasks failed:\n- lint: lint failed\n- vet: vet failed", err.Error())
+
+		assert.Equal(t, runner.TaskStatusFailed, r.TaskStatus("lint"))
+		assert.Equal(t, runner.TaskStatusFailed, r.TaskStatus("vet"))
+		assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("test"))
+		assert.Equal(t, runner.TaskStatusBlocked, r.TaskStatus("pkg"))
+		assert.Equal(t, runner.TaskStatusBlocked, r.TaskStatus("ci"))
+	})
+}
+
+// --- Test 31: Failed short tasks are retried in short runs ---
+
+func TestRetries(t *testing.T) {
+	// flaky fails until it has been attempted n times.
+	flaky := func(id string, n int) task.Task {
+		attempts := 0
+		return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			attempts++
+			if attempts < n {
+				return errors.New("flaked")
+			}
+			return nil
+		}, task.TaskMetadata{ID: id, Type: "short", Retries: 2, RetryDelay: 10 * time.Second})
+	}
+
+	t.Run("succeeds", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			mw := fixtures.NewWriter()
+			r, err := runner.New(runner.RunTypeShort, ".", task.NewLibrary(flaky("test", 3)), []string{"test"}, mw)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			start := time.Now()
+			assert.NoError(t, r.Start(t.Context()))
+			assert.Equal(t, 20*time.Second, time.Since(start))
+
+			out := mw.String("test")
+			assert.Contains(t, out, "retrying in 10 seconds")
+			assert.Contains(t, out, "starting (attempt 2 of 3)")
+			assert.Contains(t, out, "starting (attempt 3 of 3)")
+			assert.Contains(t, out, "exit ok (attempt 3 of 3)")
+		})
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			r, err := runner.New(runner.RunTypeShort, ".", task.NewLibrary(flaky("test", 4)), []string{"test"}, fixtures.NewWriter())
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			assert.EqualError(t, r.Start(t.Context()), "flaked (after 3 attempts)")
+			assert.Equal(t, runner.TaskStatusFailed, r.TaskStatus("test"))
+		})
+	})
+}
+
+// --- Test 32: Hooks and on-exit tasks run around tasks, even when canceled ---
+
+func TestHooks(t *testing.T) {
+	// newLib returns a library whose tasks record when they run in the
+	// returned log. "test" fails with testErr, and "dev" and "docker-stop"
+	// run until they're canceled.
+	newLib := func(testErr, cleanupErr error) (task.Library, *[]string) {
+		var (
+			mu  sync.Mutex
+			log []string
+		)
+		record := func(id string, err error, meta task.TaskMetadata) task.Task {
+			meta.ID = id
+			meta.Type = cmp.Or(meta.Type, "short")
+			return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				mu.Lock()
+				log = append(log, id)
+				mu.Unlock()
+				if meta.Type == "long" {
+					close(onReady)
+				}
+				if meta.Type == "long" || id == "docker-stop" {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return err
+			}, meta)
+		}
+		return task.NewLibrary(
+			record("db-create", nil, task.TaskMetadata{}),
+			record("db-drop", nil, task.TaskMetadata{}),
+			record("report", nil, task.TaskMetadata{}),
+			record("cleanup", cleanupErr, task.TaskMetadata{}),
+			record("docker-stop", nil, task.TaskMetadata{}),
+			record("test", testErr, task.TaskMetadata{Before: []string{"db-create"}, After: []string{"report"}, Finally: []string{"db-drop"}}),
+			record("dev", nil, task.TaskMetadata{Type: "long", Finally: []string{"db-drop"}}),
+		), &log
+	}
+
+	t.Run("success", func(t *testing.T) {
+		lib, log := newLib(nil, nil)
+		mw := fixtures.NewWriter()
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"test"}, mw, runner.WithOnExit("cleanup"))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Start(t.Context()))
+		assert.Equal(t, []string{"db-create", "test", "report", "db-drop", "cleanup"}, *log)
+		assert.Contains(t, mw.String("test"), "finally: db-drop")
+		assert.Contains(t, mw.String(runner.InternalTaskExit), "cleanup: exit ok")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		lib, log := newLib(errors.New("tests failed"), errors.New("cleanup failed"))
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"test"}, fixtures.NewWriter(), runner.WithOnExit("cleanup"))
+		assert.NoError(t, err)
+		assert.EqualError(t, r.Start(t.Context()), "tests failed")
+		assert.Equal(t, []string{"db-create", "test", "db-drop", "cleanup"}, *log)
+	})
+
+	t.Run("on-exit failure", func(t *testing.T) {
+		lib, _ := newLib(nil, errors.New("cleanup failed"))
+		r, err := runner.New(runner.RunTypeShort, ".", lib, []string{"test"}, fixtures.NewWriter(), runner.WithOnExit("cleanup"))
+		assert.NoError(t, err)
+		assert.EqualError(t, r.Start(t.Context()), "on-exit task 'cleanup' failed: cleanup failed")
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			lib, log := newLib(nil, nil)
+			r, err := runner.New(runner.RunTypeLong, ".", lib, []string{"dev"}, fixtures.NewWriter(), runner.WithOnExit("cleanup"))
+			assert.NoError(t, err)
+			ctx, cancel := context.WithCancel(t.Context())
+			errs := make(chan error, 1)
+			go func() { errs <- r.Start(ctx) }()
+			time.Sleep(time.Second)
+			cancel()
+			assert.NoError(t, <-errs)
+			assert.Equal(t, []string{"dev", "db-drop", "cleanup"}, *log)
+		})
+	})
+
+	t.Run("hung on-exit task", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			lib, _ := newLib(nil, nil)
+			mw := fixtures.NewWriter()
+			r, err := runner.New(runner.RunTypeLong, ".", lib, []string{"dev"}, mw,
+				runner.WithOnExit("docker-stop", "cleanup"), runner.WithOnExitTimeout(5*time.Second))
+			assert.NoError(t, err)
+			ctx, cancel := context.WithCancel(t.Context())
+			errs := make(chan error, 1)
+			go func() { errs <- r.Start(ctx) }()
+			time.Sleep(time.Second)
+			cancel()
+			err = <-errs
+			assert.ErrorIs(t, err, runner.ErrTimedOut)
+			assert.EqualError(t, err, "on-exit task 'docker-stop' failed: timed out after 5s")
+			assert.Contains(t, mw.String(runner.InternalTaskExit), "cleanup: exit ok")
+		})
+	})
+
+	t.Run("invalid on-exit tasks", func(t *testing.T) {
+		lib, _ := newLib(nil, nil)
+		for id, want := range map[string]string{
+			"missing": "On-exit task missing not found.",
+			"dev":     "On-exit task dev is long. Only short tasks can run on exit.",
+			"test":    "On-exit task test has hooks. On-exit tasks run without their hooks, so they can't have any.",
+		} {
+			_, err := runner.New(runner.RunTypeShort, ".", lib, []string{"test"}, fixtures.NewWriter(), runner.WithOnExit(id))
+			assert.EqualError(t, err, want)
+		}
+
+		lib = task.NewLibrary(
+			fixtures.NewTask("db", "short"),
+			fixtures.NewTask("cleanup", "short").WithDependencies("db"),
+		)
+		_, err := runner.New(runner.RunTypeShort, ".", lib, []string{"db"}, fixtures.NewWriter(), runner.WithOnExit("cleanup"))
+		assert.EqualError(t, err, "On-exit task cleanup has dependencies. On-exit tasks run without their dependencies, so they can't have any.")
+	})
+}
+
+// --- Test 33: Watch-triggered runs receive the changed files ---
+
+func TestChangedFiles(t *testing.T) {
+	restore := watcher.Mock()
+	defer restore()
+
+	runs := make(chan []string, 4)
+	tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs <- task.ChangedFiles(ctx)
+		close(onReady)
+		<-ctx.Done()
+		return ctx.Err()
+	}, task.TaskMetadata{
+		ID:    "lint",
+		Type:  "long",
+		Watch: []string{"src"},
+	})
+
+	_, cancel, errs := startRunWithHandle(t, []task.Task{tk}, "lint", fixtures.NewWriter())
+	defer cancel()
+
+	// The first run wasn't caused by file changes.
+	assert.Empty(t, <-runs)
+
+	watcher.Dispatch("src",
+		watcher.EventInfo{Path: "src/b.go", Event: "write"},
+		watcher.EventInfo{Path: "src/a.go", Event: "create"},
+		watcher.EventInfo{Path: "src/b.go", Event: "write"},
+	)
+	a, _ := filepath.Abs("src/a.go")
+	b, _ := filepath.Abs("src/b.go")
+	select {
+	case changed := <-runs:
+		assert.Equal(t, []string{a, b}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("task didn't rerun after the watch event")
+	}
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 34: Watch ignore patterns filter events per task ---
+
+func TestWatchIgnore(t *testing.T) {
+	restore := watcher.Mock()
+	defer restore()
+
+	runs := make(chan string, 8)
+	makeTask := func(id string, ignore ...string) task.Task {
+		return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			runs <- id
+			close(onReady)
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{
+			ID:          id,
+			Type:        "long",
+			Watch:       []string{"src"},
+			WatchIgnore: ignore,
+		})
+	}
+	tasks := []task.Task{
+		makeTask("server", "*.log"),
+		makeTask("docs"),
+		task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{ID: "dev", Type: "long", Dependencies: []string{"server", "docs"}}),
+	}
+
+	mw := fixtures.NewWriter()
+	_, cancel, errs := startRunWithHandle(t, tasks, "dev", mw)
+	defer cancel()
+
+	started := []string{<-runs, <-runs}
+	assert.ElementsMatch(t, []string{"server", "docs"}, started)
+	assert.Contains(t, mw.String(runner.InternalTaskWatch), "watching src (ignoring *.log)")
+
+	// Only the task that doesn't ignore logs reruns.
+	watcher.Dispatch("src", watcher.EventInfo{Path: "src/debug.log", Event: "write"})
+	select {
+	case id := <-runs:
+		assert.Equal(t, "docs", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("docs didn't rerun after the watch event")
+	}
+	select {
+	case id := <-runs:
+		t.Fatalf("%s reran after an ignored change", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 35: Watch events are filtered by kind and content hash ---
+
+func TestWatchEvents(t *testing.T) {
+	restore := watcher.Mock()
+	defer restore()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := make(chan []string, 8)
+	tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs <- task.ChangedFiles(ctx)
+		close(onReady)
+		<-ctx.Done()
+		return ctx.Err()
+	}, task.TaskMetadata{
+		ID:          "server",
+		Type:        "long",
+		Watch:       []string{"src"},
+		WatchEvents: []string{"write", "remove"},
+		WatchHash:   true,
+	})
+
+	_, cancel, errs := startRunWithHandle(t, []task.Task{tk}, "server", fixtures.NewWriter())
+	defer cancel()
+	<-runs
+
+	expectRun := func(changed ...string) {
+		t.Helper()
+		select {
+		case files := <-runs:
+			assert.Equal(t, changed, files)
+		case <-time.After(5 * time.Second):
+			t.Fatal("server didn't rerun")
+		}
+	}
+	expectNoRun := func() {
+		t.Helper()
+		select {
+		case files := <-runs:
+			t.Fatalf("server reran for %v", files)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Kinds of events the task doesn't watch for are dropped.
+	watcher.Dispatch("src", watcher.EventInfo{Path: file, Event: "Create"})
+	expectNoRun()
+
+	// The first change to a file that the task hasn't seen counts.
+	watcher.Dispatch("src", watcher.EventInfo{Path: file, Event: "Write"})
+	expectRun(file)
+
+	// Saving the same contents doesn't.
+	watcher.Dispatch("src", watcher.EventInfo{Path: file, Event: "Write"})
+	expectNoRun()
+
+	if err := os.WriteFile(file, []byte("package server\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	watcher.Dispatch("src", watcher.EventInfo{Path: file, Event: "Write"})
+	expectRun(file)
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	watcher.Dispatch("src", watcher.EventInfo{Path: file, Event: "Remove"})
+	expectRun(file)
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 36: Each task debounces and throttles watched changes ---
+
+func TestDebounceAndThrottle(t *testing.T) {
+	restore := watcher.Mock()
+	defer restore()
+
+	var fastCount, slowCount, throttledCount atomic.Int32
+	makeTask := func(id string, count *atomic.Int32, debounce, throttle time.Duration) task.Task {
+		return task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			count.Add(1)
+			close(onReady)
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{
+			ID:       id,
+			Type:     "long",
+			Watch:    []string{"src"},
+			Debounce: debounce,
+			Throttle: throttle,
+		})
+	}
+	tasks := []task.Task{
+		makeTask("fast", &fastCount, 10*time.Millisecond, 0),
+		makeTask("slow", &slowCount, 400*time.Millisecond, 0),
+		makeTask("throttled", &throttledCount, 10*time.Millisecond, time.Second),
+		task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{ID: "dev", Type: "long", Dependencies: []string{"fast", "slow", "throttled"}}),
+	}
+
+	_, cancel, errs := startRunWithHandle(t, tasks, "dev", fixtures.NewWriter())
+	defer cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	// Three changes, 100ms apart.
+	for range 3 {
+		watcher.Dispatch("src", watcher.EventInfo{Path: "src/main.go", Event: "write"})
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The fast task reran after each change, but the slow one is still
+	// waiting for changes to stop, and the throttled one reran once.
+	assert.Equal(t, int32(4), fastCount.Load())
+	assert.Equal(t, int32(1), slowCount.Load())
+	assert.Equal(t, int32(2), throttledCount.Load())
+
+	// Once changes stop, the slow task reruns once, and the throttled one
+	// reruns once its throttle is up.
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(4), fastCount.Load())
+	assert.Equal(t, int32(2), slowCount.Load())
+	assert.Equal(t, int32(3), throttledCount.Load())
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 37: Polling watchers rerun tasks too ---
+
+func TestPollingWatch(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("src", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := make(chan []string, 4)
+	tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs <- task.ChangedFiles(ctx)
+		close(onReady)
+		<-ctx.Done()
+		return ctx.Err()
+	}, task.TaskMetadata{
+		ID:    "server",
+		Type:  "long",
+		Watch: []string{"src"},
+	})
+
+	mw := fixtures.NewWriter()
+	r, err := runner.New(runner.RunTypeLong, ".", task.NewLibrary(tk), []string{"server"}, mw,
+		runner.WithWatchMode("poll", 10*time.Millisecond),
+		runner.WithDebounce(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- r.Start(ctx) }()
+
+	assert.Empty(t, <-runs)
+	assert.Contains(t, mw.String(runner.InternalTaskWatch), "watching src (polling every 10ms)")
+
+	if err := os.WriteFile("src/main.go", []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs("src/main.go")
+	select {
+	case changed := <-runs:
+		assert.Equal(t, []string{abs}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't rerun after a polled change")
+	}
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 38: Args and env file contents are part of the fingerprint ---
+
+func TestUpToDateArgsAndEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644))
+	assert.NoError(t, os.WriteFile(envFile, []byte("MODE=dev\n"), 0o644))
+
+	test := task.ScriptTask(`echo "$MODE:$RUN_ARGS" >> runs.log`, dir, nil, task.TaskMetadata{
+		ID:      "test",
+		Type:    "short",
+		Sources: []string{"*.go"},
+	}, task.WithEnvFiles(envFile))
+	lib := task.NewLibrary(test)
+
+	run := func(args ...string) {
+		t.Helper()
+		r, err := runner.New(runner.RunTypeShort, dir, lib, []string{"test"}, fixtures.NewWriter(),
+			runner.WithStateDir(stateDir), runner.WithArgs(args...))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+	}
+	runs := func() []string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, "runs.log"))
+		assert.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(b)), "\n")
+	}
+
+	run()
+	run()
+	assert.Equal(t, []string{"dev:"}, runs())
+
+	// Different args make the task run again.
+	run("-run", "X")
+	run("-run", "X")
+	assert.Equal(t, []string{"dev:", "dev:-run X"}, runs())
+
+	// So does editing the env file.
+	assert.NoError(t, os.WriteFile(envFile, []byte("MODE=prod\n"), 0o644))
+	run("-run", "X")
+	run("-run", "X")
+	assert.Equal(t, []string{"dev:", "dev:-run X", "prod:-run X"}, runs())
+}
+
+// --- Test 39: Watching from a subdirectory of the workspace ---
+
+func TestWatchFromSubdirectory(t *testing.T) {
+	for _, mode := range []string{"notify", "poll"} {
+		t.Run(mode, func(t *testing.T) {
+			root := t.TempDir()
+			assert.NoError(t, os.MkdirAll(filepath.Join(root, "web"), 0o755))
+			assert.NoError(t, os.MkdirAll(filepath.Join(root, "api"), 0o755))
+			t.Chdir(filepath.Join(root, "api"))
+
+			runs := make(chan []string, 4)
+			tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+				runs <- task.ChangedFiles(ctx)
+				close(onReady)
+				<-ctx.Done()
+				return ctx.Err()
+			}, task.TaskMetadata{
+				ID:          "web",
+				Type:        "long",
+				Watch:       []string{"web/*.js"},
+				WatchIgnore: []string{"web/skip.js"},
+			})
+
+			// As when run is started in api and the workspace root is "..".
+			r, err := runner.New(runner.RunTypeLong, "..", task.NewLibrary(tk), []string{"web"}, fixtures.NewWriter(),
+				runner.WithWatchMode(mode, 10*time.Millisecond),
+				runner.WithDebounce(50*time.Millisecond))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			errs := make(chan error, 1)
+			go func() { errs <- r.Start(ctx) }()
+			assert.Empty(t, <-runs)
+
+			assert.NoError(t, os.WriteFile(filepath.Join(root, "web", "skip.js"), nil, 0o644))
+			assert.NoError(t, os.WriteFile(filepath.Join(root, "web", "app.js"), nil, 0o644))
+			select {
+			case changed := <-runs:
+				assert.Equal(t, []string{filepath.Join(root, "web", "app.js")}, changed)
+			case <-time.After(5 * time.Second):
+				t.Fatal("web didn't rerun after a change to a watched file")
+			}
+
+			cancel()
+			waitFor(t, errs, 5*time.Second)
+		})
+	}
+}
+
+// --- Test 40: Status commands see the task's environment ---
+
+func TestStatusEnv(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("NAME=out\n"), 0o644))
+
+	build := task.ScriptTask(`touch "$OUT_DIR/$NAME"; echo ran >> runs.log`, dir, []string{"OUT_DIR=" + dir}, task.TaskMetadata{
+		ID:     "build",
+		Type:   "short",
+		Status: `test -f "$OUT_DIR/$NAME"`,
+	}, task.WithEnvFiles(envFile))
+	lib := task.NewLibrary(build)
+
+	run := func() *fixtures.Writer {
+		t.Helper()
+		mw := fixtures.NewWriter()
+		r, err := runner.New(runner.RunTypeShort, dir, lib, []string{"build"}, mw)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.NoError(t, r.Start(t.Context()))
+		return mw
+	}
+
+	run()
+	mw := run()
+	assert.Contains(t, mw.String("build"), "skipped (status ok)")
+	b, err := os.ReadFile(filepath.Join(dir, "runs.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ran\n", string(b))
+}
+
+// --- Test 41: Tasks that recover get a fresh set of restarts ---
+
+func TestMaxRestartsAfterRecovery(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		var starts atomic.Int32
+		server := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			n := starts.Add(1)
+			close(onReady)
+			// Stay up for a while before the first few crashes.
+			if n <= 5 {
+				time.Sleep(time.Second)
+			}
+			return errors.New("boom")
+		}, task.TaskMetadata{
+			ID:             "server",
+			Type:           "long",
+			MaxRestarts:    2,
+			BackoffInitial: 100 * time.Millisecond,
+			BackoffMax:     150 * time.Millisecond,
+		})
+
+		r, cancel, errs := startRunWithHandle(t, []task.Task{server}, "server", mw)
+
+		time.Sleep(20 * time.Second)
+		synctest.Wait()
+		// Five crashes after recovering, then three in a row.
+		assert.Equal(t, int32(7), starts.Load())
+		assert.Equal(t, runner.TaskStatusCrashLooping, r.TaskStatus("server"))
+		assert.Equal(t, 5, strings.Count(mw.String("server"), "retrying in 100ms"))
+		assert.Equal(t, 1, strings.Count(mw.String("server"), "retrying in 150ms"))
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+	})
+}
+
+// --- Test 42: Reading the cache doesn't hold up other tasks ---
+
+// blockingCache is a cache.Cache whose Get waits until release is closed.
+type blockingCache struct{ release chan struct{} }
+
+func (c blockingCache) Get(key string) (io.ReadCloser, error) {
+	<-c.release
+	return nil, cache.ErrNotFound
+}
+
+func (c blockingCache) Put(key string, r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func TestCacheDoesNotBlock(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "schema.graphql"), []byte("type A"), 0o644))
+
+	c := blockingCache{release: make(chan struct{})}
+	codegen := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		return os.WriteFile(filepath.Join(dir, "gen.go"), []byte("package gen"), 0o644)
+	}, task.TaskMetadata{
+		ID:      "codegen",
+		Type:    "short",
+		Sources: []string{"*.graphql"},
+		Outputs: []string{"gen.go"},
+	})
+	lint := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		// Lint runs while codegen is still reading the cache.
+		close(c.release)
+		return nil
+	}, task.TaskMetadata{ID: "lint", Type: "short"})
+
+	r, err := runner.New(runner.RunTypeShort, dir, task.NewLibrary(codegen, lint), []string{"codegen", "lint"}, fixtures.NewWriter(), runner.WithCache(c))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- r.Start(t.Context()) }()
+	assert.NoError(t, waitFor(t, errs, 5*time.Second))
+	assert.Equal(t, runner.TaskStatusDone, r.TaskStatus("codegen"))
+}
+
+// --- Test 43: Cmd probes see the task's environment ---
+
+func TestProbeEnv(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte("PORT=5432\n"), 0o644))
+
+	db := task.ScriptTask("sleep 60", dir, []string{"USER=app"}, task.TaskMetadata{
+		ID:    "db",
+		Type:  "long",
+		Ready: &task.Probe{Cmd: `test "$USER:$PORT" = app:5432`, Interval: 10 * time.Millisecond, Timeout: 2 * time.Second},
+	}, task.WithEnvFiles(envFile))
+	api := fixtures.NewTask("api", "short").WithDependencies("db")
+
+	mw := fixtures.NewWriter()
+	r, err := runner.New(runner.RunTypeShort, dir, task.NewLibrary(db, api), []string{"api"}, mw)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	assert.NoError(t, r.Start(t.Context()))
+	assert.Contains(t, mw.String("api"), "! api: execute")
+}
+
+// --- Test 44: Runs wait for blocked tasks to stop ---
+
+func TestKeepGoingWaitsForBlockedTasks(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		db := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			close(onReady)
+			time.Sleep(time.Second)
+			return errors.New("db crashed")
+		}, task.TaskMetadata{ID: "db", Type: "long"})
+		var stopped atomic.Bool
+		api := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			<-ctx.Done()
+			// Stopping takes a while.
+			time.Sleep(5 * time.Second)
+			stopped.Store(true)
+			return ctx.Err()
+		}, task.TaskMetadata{ID: "api", Type: "short", Dependencies: []string{"db"}})
+
+		r, err := runner.New(runner.RunTypeShort, ".", task.NewLibrary(db, api), []string{"api"}, fixtures.NewWriter(), runner.WithKeepGoing(true))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		assert.EqualError(t, r.Start(t.Context()), "1 task failed:\n- db: db crashed")
+		assert.True(t, stopped.Load())
+		assert.Equal(t, runner.TaskStatusBlocked, r.TaskStatus("api"))
+	})
+}
+
+// --- Test 45: Liveness failures count toward max restarts ---
+
+func TestLivenessProbeCrashLooping(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+
+		var starts atomic.Int32
+		server := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			starts.Add(1)
+			close(onReady)
+			<-ctx.Done()
+			return ctx.Err()
+		}, task.TaskMetadata{
+			ID:             "server",
+			Type:           "long",
+			Liveness:       &task.Probe{Log: "tick", Interval: 100 * time.Millisecond, FailureThreshold: 2},
+			MaxRestarts:    2,
+			BackoffInitial: 100 * time.Millisecond,
+			BackoffMax:     150 * time.Millisecond,
+		})
+
+		r, cancel, errs := startRunWithHandle(t, []task.Task{server}, "server", mw)
+
+		time.Sleep(10 * time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(3), starts.Load())
+		assert.Equal(t, runner.TaskStatusCrashLooping, r.TaskStatus("server"))
+		assert.Contains(t, mw.String("server"), "retrying in 100ms")
+		assert.Contains(t, mw.String("server"), "retrying in 150ms")
+		assert.Contains(t, mw.String("server"), "crash-looping: giving up after 2 restarts")
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+	})
+}
+
+// --- Test 46: Watching a file that doesn't exist yet ---
+
+func TestWatchMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	runs := make(chan struct{}, 10)
+	tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs <- struct{}{}
+		close(onReady)
+		<-ctx.Done()
+		return ctx.Err()
+	}, task.TaskMetadata{
+		ID:    "server",
+		Type:  "long",
+		Watch: []string{".env"},
+	})
+
+	r, err := runner.New(runner.RunTypeLong, dir, task.NewLibrary(tk), []string{"server"}, fixtures.NewWriter(), runner.WithDebounce(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, 1)
+	go func() { errs <- r.Start(ctx) }()
+	defer cancel()
+	select {
+	case <-runs:
+	case err := <-errs:
+		t.Fatalf("Start: %v", err)
+	}
+
+	// The file's directory is watched, but other files' changes are
+	// ignored.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), nil, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\n"), 0o644))
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't rerun once the file was created")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, runs)
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 47: Fingerprinting sources doesn't block other tasks ---
+
+func TestFingerprintInExecutor(t *testing.T) {
+	dir := t.TempDir()
+	// Hashing a FIFO blocks until something writes to it.
+	fifo := filepath.Join(dir, "src.fifo")
+	if err := syscall.Mkfifo(fifo, 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	noop := func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error { return nil }
+	lib := task.NewLibrary(
+		task.FuncTask(noop, task.TaskMetadata{ID: "build", Type: "short", Sources: []string{"src.fifo"}}),
+		task.FuncTask(noop, task.TaskMetadata{ID: "lint", Type: "short"}),
+	)
+	r, err := runner.New(runner.RunTypeShort, dir, lib, []string{"build", "lint"}, fixtures.NewWriter(), runner.WithStateDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- r.Start(t.Context()) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.TaskStatus("lint") != runner.TaskStatusDone {
+		if time.Now().After(deadline) {
+			t.Fatal("lint didn't finish while build's sources were being hashed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NoError(t, os.WriteFile(fifo, []byte("package main\n"), 0o644))
+	assert.NoError(t, waitFor(t, errs, 5*time.Second))
+}
+
+// --- Test 48: Watch hashes start from the files' contents at the task's start ---
+
+func TestWatchHashSeeded(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := make(chan struct{}, 10)
+	tk := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+		runs <- struct{}{}
+		close(onReady)
+		<-ctx.Done()
+		return ctx.Err()
+	}, task.TaskMetadata{
+		ID:        "server",
+		Type:      "long",
+		Watch:     []string{"."},
+		WatchHash: true,
+	})
+
+	r, err := runner.New(runner.RunTypeLong, dir, task.NewLibrary(tk), []string{"server"}, fixtures.NewWriter(), runner.WithDebounce(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, 1)
+	go func() { errs <- r.Start(ctx) }()
+	defer cancel()
+	select {
+	case <-runs:
+	case err := <-errs:
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Saving a file without changing it doesn't count, even the first time.
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-runs:
+		t.Fatal("server reran for an unchanged file")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(file, []byte("package server\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't rerun")
+	}
+
+	cancel()
+	waitFor(t, errs, 5*time.Second)
+}
+
+// --- Test 49: Restart settings are validated ---
+
+func TestRestartSettings(t *testing.T) {
+	lib := task.NewLibrary(fixtures.NewTask("server", "long"))
+	for _, tt := range []struct {
+		opt  runner.Option
+		want string
+	}{
+		{runner.WithRestart("sometimes"), "Invalid restart policy 'sometimes'; must be 'always', 'on-failure', or 'never'."},
+		{runner.WithMaxRestarts(-1), "Max restarts can't be negative."},
+		{runner.WithBackoff(-time.Second, 0), "Backoff can't be negative."},
+		{runner.WithBackoff(time.Minute, time.Second), "Backoff max 1s is less than backoff initial 1m0s."},
+	} {
+		_, err := runner.New(runner.RunTypeLong, ".", lib, []string{"server"}, fixtures.NewWriter(), tt.opt)
+		assert.EqualError(t, err, tt.want)
+	}
+
+	// A task's initial backoff isn't capped by the default maximum.
+	synctest.Test(t, func(t *testing.T) {
+		mw := fixtures.NewWriter()
+		var starts atomic.Int32
+		server := task.FuncTask(func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error {
+			starts.Add(1)
+			return errors.New("boom")
+		}, task.TaskMetadata{ID: "server", Type: "long", BackoffInitial: 45 * time.Second})
+
+		_, cancel, errs := startRunWithHandle(t, []task.Task{server}, "server", mw)
+		time.Sleep(44 * time.Second)
+		synctest.Wait()
+		assert.Equal(t, int32(1), starts.Load())
+		assert.Contains(t, mw.String("server"), "retrying in 45 seconds")
+
+		cancel()
+		waitFor(t, errs, 5*time.Second)
+		// Let the pending restart's delay end.
+		time.Sleep(time.Second)
+	})
+}
@@ -0,0 +1,27 @@
+package runner
+
+import (
+	"context"
+	"io"
+
+	"monks.co/run/internal/script"
+	"monks.co/run/task"
+)
+
+// statusOK runs t's status command, returning true if it exits 0, meaning
+// that t needn't run.
+func (r *Run) statusOK(ctx context.Context, t task.Task) bool {
+	s := r.taskScript(t, t.Metadata().Status)
+	return s.Start(ctx, io.Discard, io.Discard) == nil
+}
+
+// taskScript returns a script with the given text for a command that
+// belongs to t, like its status command or a probe. For script tasks, it
+// runs in the task's directory and environment; otherwise it runs in the
+// run's directory.
+func (r *Run) taskScript(t task.Task, text string) script.Script {
+	if st, ok := t.(interface{ Script(string) script.Script }); ok {
+		return st.Script(text)
+	}
+	return script.Script{Dir: r.dir, Text: text}
+}
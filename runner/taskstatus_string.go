@@ -15,11 +15,17 @@ func _() {
 	_ = x[TaskStatusFailed-4]
 	_ = x[TaskStatusCanceled-5]
 	_ = x[TaskStatusDone-6]
+	_ = x[TaskStatusStarting-7]
+	_ = x[TaskStatusUnhealthy-8]
+	_ = x[TaskStatusCrashLooping-9]
+	_ = x[TaskStatusTimedOut-10]
+	_ = x[TaskStatusQueued-11]
+	_ = x[TaskStatusBlocked-12]
 }
 
-const _TaskStatus_name = "taskStatusInvalidTaskStatusNotStartedTaskStatusRunningTaskStatusRestartingTaskStatusFailedTaskStatusCanceledTaskStatusDone"
+const _TaskStatus_name = "taskStatusInvalidTaskStatusNotStartedTaskStatusRunningTaskStatusRestartingTaskStatusFailedTaskStatusCanceledTaskStatusDoneTaskStatusStartingTaskStatusUnhealthyTaskStatusCrashLoopingTaskStatusTimedOutTaskStatusQueuedTaskStatusBlocked"
 
-var _TaskStatus_index = [...]uint8{0, 17, 37, 54, 74, 90, 108, 122}
+var _TaskStatus_index = [...]uint8{0, 17, 37, 54, 74, 90, 108, 122, 140, 159, 181, 199, 215, 232}
 
 func (i TaskStatus) String() string {
 	idx := int(i) - 0
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"monks.co/run/internal/executor"
+)
+
+// ErrTimedOut is wrapped by the error a task exits with when it runs longer
+// than its [task.TaskMetadata] Timeout, and by the error [Run.Start] returns
+// when a run exceeds the limit set by [WithTimeout].
+var ErrTimedOut = errors.New("timed out")
+
+// WithTimeout limits how long a run may take. If it hasn't finished after d,
+// the run cancels its tasks, marks any that were still running as timed out,
+// and [Run.Start] returns an error wrapping [ErrTimedOut]. By default there
+// is no limit.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Run) { r.timeout = d }
+}
+
+// enforceTimeout cancels exec if it's still running after d, recording that
+// it did so in timedOut.
+func enforceTimeout(exec *executor.Executor, d time.Duration, timedOut *atomic.Bool) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-exec.Done():
+	case <-timer.C:
+		timedOut.Store(true)
+		exec.Cancel()
+	}
+}
+
+// markTimedOut gives every task that is still in progress the status
+// TaskStatusTimedOut.
+func (r *Run) markTimedOut() {
+	r.mu.Lock("markTimedOut")
+	var ids []string
+	for id, s := range r.taskStatus {
+		switch s {
+		case TaskStatusRunning, TaskStatusRestarting, TaskStatusStarting, TaskStatusUnhealthy, TaskStatusQueued:
+			r.taskStatus[id] = TaskStatusTimedOut
+			ids = append(ids, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		r.printf(id, logStyle, "timed out")
+	}
+}
@@ -0,0 +1,259 @@
+package runner
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"monks.co/run/internal/fingerprint"
+	"monks.co/run/internal/watcher"
+	"monks.co/run/task"
+)
+
+// WithWatchMode sets how the run's file watchers find changes: "notify",
+// which uses the operating system's file events, "poll", which checks for
+// changes every interval, or "auto", which uses notify unless a self-test
+// finds that it gets no events. If interval is zero, it's 1 second. By
+// default, watchers use notify.
+func WithWatchMode(mode string, interval time.Duration) Option {
+	return func(r *Run) {
+		r.watchMode = mode
+		r.pollEvery = interval
+	}
+}
+
+// A watch is a watched path along with the rules for which of its changes
+// to ignore. Tasks that watch the same path with the same rules share a
+// file watcher.
+type watch struct {
+	path      string
+	ignore    string // watch ignore patterns, separated by newlines
+	gitignore bool
+}
+
+// watchesFor returns the watches of the task with the given metadata.
+func watchesFor(tm task.TaskMetadata) []watch {
+	ws := make([]watch, len(tm.Watch))
+	for i, p := range tm.Watch {
+		ws[i] = watch{
+			path:      p,
+			ignore:    strings.Join(tm.WatchIgnore, "\n"),
+			gitignore: tm.WatchGitignore,
+		}
+	}
+	return ws
+}
+
+// watchesOf returns the unique watches of the tasks in lib, sorted by path.
+func watchesOf(lib task.Library) []watch {
+	var ws []watch
+	for _, id := range lib.IDs() {
+		for _, w := range watchesFor(lib.Get(id).Metadata()) {
+			if !slices.Contains(ws, w) {
+				ws = append(ws, w)
+			}
+		}
+	}
+	slices.SortFunc(ws, func(a, b watch) int {
+		return cmp.Or(
+			strings.Compare(a.path, b.path),
+			strings.Compare(a.ignore, b.ignore),
+			cmp.Compare(boolInt(a.gitignore), boolInt(b.gitignore)),
+		)
+	})
+	return ws
+}
+
+// withWatch returns the IDs of the tasks in lib that have the watch w.
+func withWatch(lib task.Library, w watch) []string {
+	var ids []string
+	for _, id := range lib.IDs() {
+		if slices.Contains(watchesFor(lib.Get(id).Metadata()), w) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// triggeringEvents returns the events in evs that should rerun the task with
+// the given metadata: those of the kinds in its WatchEvents, and, if it has
+// WatchHash, only those for files whose contents have changed since they
+// last triggered it. hashes holds the current hashes of the events' files;
+// see [hashFiles].
+func (r *Run) triggeringEvents(tm task.TaskMetadata, evs []watcher.EventInfo, hashes map[string]string) []watcher.EventInfo {
+	var out []watcher.EventInfo
+	for _, ev := range evs {
+		if len(tm.WatchEvents) > 0 && !slices.ContainsFunc(tm.WatchEvents, func(kind string) bool {
+			return strings.EqualFold(kind, ev.Event)
+		}) {
+			continue
+		}
+		if tm.WatchHash && !r.contentChanged(tm.ID, ev.Path, hashes) {
+			continue
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+// contentChanged returns true if the file at p has changed since it last
+// triggered the task with the given ID, or since the task first started, and
+// records its current hash, from hashes. A file that doesn't exist has an
+// empty hash, so deleting a file counts as a change, but deleting it again
+// doesn't.
+func (r *Run) contentChanged(id, p string, hashes map[string]string) bool {
+	hash, ok := hashes[p]
+	if !ok {
+		// A directory, or a file that can't be read: assume it changed.
+		return true
+	}
+	defer r.mu.Lock("contentChanged").Unlock()
+	if r.hashes[id] == nil {
+		r.hashes[id] = map[string]string{}
+	}
+	last, seen := r.hashes[id][p]
+	r.hashes[id][p] = hash
+	return !seen || last != hash
+}
+
+// hashFiles returns the hashes of the files at paths, for tasks with
+// WatchHash. A file that doesn't exist has an empty hash; one that can't be
+// hashed, like a directory, is left out. Since hashing can take a while, it
+// happens outside of the event loop.
+func hashFiles(paths []string) map[string]string {
+	hashes := map[string]string{}
+	for _, p := range paths {
+		hash, err := fingerprint.File(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		hashes[p] = hash
+	}
+	return hashes
+}
+
+// seedHashes records the hashes of the files that the task with metadata tm
+// watches, if it has WatchHash and they haven't been recorded yet, so that
+// only the changes since the task first started rerun it. Files that have
+// already triggered the task keep the hashes recorded then.
+func (r *Run) seedHashes(tm task.TaskMetadata) {
+	if !tm.WatchHash {
+		return
+	}
+	r.mu.Lock("seedHashes:check")
+	_, started := r.hashes[tm.ID]
+	seeded := map[string]string{}
+	if !started {
+		r.hashes[tm.ID] = seeded
+	}
+	r.mu.Unlock()
+	if started {
+		return
+	}
+
+	var paths []string
+	dir := watchDir(r.dir)
+	for _, w := range watchesFor(tm) {
+		paths = append(paths, watchedFiles(filepath.Join(dir, w.path))...)
+	}
+	hashes := hashFiles(paths)
+
+	defer r.mu.Lock("seedHashes").Unlock()
+	for p, hash := range hashes {
+		if _, seen := seeded[p]; !seen {
+			seeded[p] = hash
+		}
+	}
+}
+
+// watchedFiles returns the files that a watcher for p reports changes to,
+// in the same form as the paths of its events.
+func watchedFiles(p string) []string {
+	watchPath, glob := watcher.Split(p)
+	root := watchPath
+	recursive := watchPath == "..." || strings.HasSuffix(watchPath, string(filepath.Separator)+"...")
+	if recursive {
+		root = filepath.Dir(watchPath)
+	}
+	var files []string
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case d.IsDir() && p != root && !recursive:
+			return fs.SkipDir
+		case d.IsDir():
+			return nil
+		}
+		if glob == nil || glob.Match(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
+
+// options returns the watcher options for w, in a run in dir.
+func (w watch) options(dir string) watcher.Options {
+	opts := watcher.Options{IgnoreFiles: w.gitignore}
+	if w.ignore != "" {
+		for _, pattern := range strings.Split(w.ignore, "\n") {
+			opts.Ignore = append(opts.Ignore, watcher.JoinIgnore(dir, pattern))
+		}
+	}
+	return opts
+}
+
+// watchDir returns dir relative to the working directory, if it's absolute
+// and that's possible, since the watcher only applies .gitignore files to
+// relative paths.
+func watchDir(dir string) string {
+	if !filepath.IsAbs(dir) {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	if rel, err := filepath.Rel(wd, dir); err == nil {
+		return rel
+	}
+	return dir
+}
+
+// describeOptions describes a watcher with opts for the "watching" message,
+// as in " (polling every 1s, ignoring node_modules and *.log)".
+func describeOptions(opts watcher.Options) string {
+	var details []string
+	if opts.Mode == watcher.ModePoll {
+		details = append(details, fmt.Sprintf("polling every %s", cmp.Or(opts.PollInterval, watcher.DefaultPollInterval)))
+	}
+	ignored := slices.Clone(opts.Ignore)
+	if opts.IgnoreFiles {
+		ignored = append(ignored, "paths ignored by git")
+	}
+	switch len(ignored) {
+	case 0:
+	case 1:
+		details = append(details, "ignoring "+ignored[0])
+	default:
+		details = append(details, fmt.Sprintf("ignoring %s and %s", strings.Join(ignored[:len(ignored)-1], ", "), ignored[len(ignored)-1]))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
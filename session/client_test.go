@@ -18,7 +18,7 @@ func TestClientStatus(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long", Dependencies: []string{"a"}}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -61,7 +61,7 @@ func TestClientRestart(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +97,7 @@ func TestClientEnableDisableLog(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
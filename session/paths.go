@@ -10,7 +10,13 @@ import (
 // The session is identified by its root task name and the directory where
 // run was invoked.
 func SocketPath(name string, dir string) string {
-	return filepath.Join(stateDir(), dirSlug(dir), name+".sock")
+	return filepath.Join(StateDir(dir), name+".sock")
+}
+
+// StateDir returns the directory where run keeps state, like session sockets
+// and task fingerprints, for runs invoked in dir.
+func StateDir(dir string) string {
+	return filepath.Join(stateDir(), dirSlug(dir))
 }
 
 // LogFilePath returns the path to the log file for a task within a session.
@@ -20,6 +26,16 @@ func LogFilePath(sessionName string, dir string, taskID string) string {
 	return filepath.Join(dataDir(), dirSlug(dir), sessionName, "logs", taskSlug(taskID)+".log")
 }
 
+// CacheDir returns the directory where run caches task outputs. The cache is
+// shared by every directory that run is invoked in.
+func CacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic("session: cannot determine home directory: " + err.Error())
+	}
+	return filepath.Join(home, ".cache", "run")
+}
+
 func dirSlug(dir string) string {
 	dir = normalizePath(dir)
 	dir = strings.TrimPrefix(dir, "/")
@@ -27,6 +27,12 @@ func TestStatusString(t *testing.T) {
 		{"TaskStatusDone", "done"},
 		{"TaskStatusRestarting", "restarting"},
 		{"TaskStatusCanceled", "canceled"},
+		{"TaskStatusStarting", "starting"},
+		{"TaskStatusUnhealthy", "unhealthy"},
+		{"TaskStatusCrashLooping", "crash_looping"},
+		{"TaskStatusTimedOut", "timed_out"},
+		{"TaskStatusQueued", "queued"},
+		{"TaskStatusBlocked", "blocked"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
@@ -48,7 +54,7 @@ func TestSessionGetTasks(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long", Dependencies: []string{"a"}}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -120,7 +126,7 @@ func TestSessionRestart(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -170,7 +176,7 @@ func TestSessionRestartSlashID(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long", Dependencies: []string{"apps/air/build"}}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -205,7 +211,7 @@ func TestSessionRestartNotFound(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -241,7 +247,7 @@ func TestSessionEnableDisableLog(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -312,7 +318,7 @@ func TestSessionLogSlashID(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "root", Type: "long", Dependencies: []string{"apps/air/build"}}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "root", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"root"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -359,7 +365,7 @@ func TestStaleSocketCleanup(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "stale", Type: "long"}),
 	)
 
-	r, err := runner.New(runner.RunTypeLong, taskDir, lib, "stale", &noopMultiWriter{})
+	r, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"stale"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -385,7 +391,7 @@ func TestDuplicateSessionFails(t *testing.T) {
 		task.FuncTask(nil, task.TaskMetadata{ID: "dup", Type: "long"}),
 	)
 
-	r1, err := runner.New(runner.RunTypeLong, taskDir, lib, "dup", &noopMultiWriter{})
+	r1, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"dup"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -397,7 +403,7 @@ func TestDuplicateSessionFails(t *testing.T) {
 	}
 	defer sess1.Close()
 
-	r2, err := runner.New(runner.RunTypeLong, taskDir, lib, "dup", &noopMultiWriter{})
+	r2, err := runner.New(runner.RunTypeLong, taskDir, lib, []string{"dup"}, &noopMultiWriter{})
 	if err != nil {
 		t.Fatal(err)
 	}
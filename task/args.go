@@ -0,0 +1,19 @@
+package task
+
+import "context"
+
+type argsKey struct{}
+
+// WithArgs returns a copy of ctx that carries args, the extra command line
+// arguments for a task, as in `run test -- -run TestFoo`. A [runner.Run]
+// passes its args to its root task this way.
+func WithArgs(ctx context.Context, args []string) context.Context {
+	return context.WithValue(ctx, argsKey{}, args)
+}
+
+// Args returns the extra command line arguments carried by ctx, if any. A
+// task's Start can call Args on its context to receive them.
+func Args(ctx context.Context) []string {
+	args, _ := ctx.Value(argsKey{}).([]string)
+	return args
+}
@@ -0,0 +1,20 @@
+package task
+
+import "context"
+
+type changedFilesKey struct{}
+
+// WithChangedFiles returns a copy of ctx that carries files, the paths of
+// the watched files whose changes caused a task to run. A [runner.Run]
+// passes them to the tasks it reruns because of file changes this way.
+func WithChangedFiles(ctx context.Context, files []string) context.Context {
+	return context.WithValue(ctx, changedFilesKey{}, files)
+}
+
+// ChangedFiles returns the paths of the changed files carried by ctx, if
+// any. They're absolute. A task's Start can call ChangedFiles on its context
+// to operate on only the files that changed, as a linter might.
+func ChangedFiles(ctx context.Context) []string {
+	files, _ := ctx.Value(changedFilesKey{}).([]string)
+	return files
+}
@@ -7,7 +7,9 @@ import (
 
 // FuncTask produces a runnable Task from a go function. The function receives
 // an onReady channel that it should close when it is ready (i.e., has produced
-// whatever output dependents need). metadata.Dir is ignored.
+// whatever output dependents need). metadata.Dir is ignored. If the task is
+// the root of a run with extra arguments, fn can get them by calling [Args] on
+// its context.
 func FuncTask(fn func(ctx context.Context, onReady chan<- struct{}, w io.Writer) error, metadata TaskMetadata) Task {
 	return &funcTask{
 		fn:       fn,
@@ -168,7 +168,9 @@ func (lib Library) matches(pred func(Task) bool) []string {
 // Validate inspects a Library and returns an error if
 // it is invalid. If the error is not nil, its
 // [error.Error] will return a formatted multiline string
-// describing the problems with the task set.
+// describing the problems with the task set. If the
+// Library itself is invalid, rather than the environment
+// it was validated in, the error is a [*ValidationError].
 func (lib Library) Validate() error {
 	return newValidator().validate(lib)
 }
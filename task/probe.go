@@ -0,0 +1,43 @@
+package task
+
+import "time"
+
+// A Probe describes a check that a [runner.Run] performs against a running
+// task to learn whether it is up (a readiness probe) or whether it is still
+// working (a liveness probe). Exactly one of HTTP, TCP, Log, or Cmd should be
+// set.
+type Probe struct {
+	// HTTP is a URL. The probe passes when a GET request to it returns a
+	// status code below 400.
+	HTTP string
+
+	// TCP is a network address, like "localhost:5432" or ":5432". The
+	// probe passes when a TCP connection to it succeeds.
+	TCP string
+
+	// Log is a regular expression. The probe passes if the task has
+	// written a line of output matching it since the previous check. As a
+	// liveness probe, this expects the task to log a heartbeat.
+	Log string
+
+	// Cmd is a bash script. The probe passes when it exits 0. It runs in
+	// the task's directory and, for script tasks, its environment.
+	Cmd string
+
+	// Interval is the time between attempts. If it is zero, the runner
+	// uses 1 second.
+	Interval time.Duration
+
+	// Timeout bounds how long the runner waits for a readiness probe to
+	// pass before failing the task. If it is zero, the runner waits
+	// indefinitely.
+	//
+	// For liveness probes, Timeout instead bounds each individual check.
+	// If it is zero, the runner uses Interval.
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed checks after
+	// which a liveness probe considers the task unhealthy. If it is zero,
+	// the runner uses 3. Readiness probes ignore it.
+	FailureThreshold int
+}
@@ -3,7 +3,12 @@ package task
 import (
 	"context"
 	"io"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
+	"monks.co/run/internal/fingerprint"
 	"monks.co/run/internal/script"
 )
 
@@ -12,16 +17,58 @@ import (
 // Stderr will be provided by the Run, and will be forwarded to the UI. The
 // script will not get a Stdin.
 //
+// Any [Args] in Start's context are passed to the script as "$@", and, joined
+// by spaces, as $RUN_ARGS.
+//
 // Script runs in a new bash process, and can have multiple lines. It is run
 // basically like this:
 //
 //	$ cd $DIR
 //	$ bash -c "$CMD" 2&>1 /some/ui
-func ScriptTask(scriptText string, dir string, env []string, metadata TaskMetadata) Task {
-	return &scriptTask{
+//
+// When the run stops the task, whether to restart it or during shutdown, the
+// script's process group is sent SIGINT, and then SIGKILL if it hasn't
+// exited after 2 seconds. [ScriptOption] values can change that behavior.
+func ScriptTask(scriptText string, dir string, env []string, metadata TaskMetadata, opts ...ScriptOption) Task {
+	t := &scriptTask{
 		script:   script.Script{Dir: dir, Env: env, Text: scriptText},
 		metadata: metadata,
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// A ScriptOption configures a [ScriptTask].
+type ScriptOption func(*scriptTask)
+
+// WithEnvFiles sets paths to .env files whose variables are added to the
+// script's environment each time it starts. Later files take precedence over
+// earlier ones, and env, as passed to [ScriptTask], takes precedence over all
+// of them. Files that don't exist are skipped.
+func WithEnvFiles(paths ...string) ScriptOption {
+	return func(t *scriptTask) { t.script.EnvFiles = paths }
+}
+
+// WithStopSignal sets the signal sent to the script's process group to stop
+// it, in place of SIGINT.
+func WithStopSignal(sig syscall.Signal) ScriptOption {
+	return func(t *scriptTask) { t.script.StopSignal = sig }
+}
+
+// WithStopTimeout sets how long to wait for the script to exit after asking
+// it to stop, before killing it, in place of 2 seconds.
+func WithStopTimeout(d time.Duration) ScriptOption {
+	return func(t *scriptTask) { t.script.StopTimeout = d }
+}
+
+// WithStopCommand sets a bash script, like "pg_ctl stop", that is run to stop
+// the script in place of sending it a signal. It runs in the task's directory
+// and environment. The stop timeout covers both the stop command and the wait
+// for the script to exit afterward.
+func WithStopCommand(stopText string) ScriptOption {
+	return func(t *scriptTask) { t.script.StopText = stopText }
 }
 
 type scriptTask struct {
@@ -37,6 +84,31 @@ func (t *scriptTask) Dir() string {
 	return t.script.Dir
 }
 
+// Fingerprint returns a string that changes whenever the script's text,
+// directory, or environment, including the contents of its env files, does,
+// for deciding whether the task is up to date.
+func (t *scriptTask) Fingerprint() string {
+	env := slices.Sorted(slices.Values(t.script.Env))
+	parts := []string{
+		t.script.Text,
+		t.script.Dir,
+		strings.Join(env, "\x00"),
+	}
+	for _, p := range t.script.EnvFiles {
+		// A missing file hashes as empty, since it's skipped.
+		sum, _ := fingerprint.File(p)
+		parts = append(parts, p, sum)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// Script returns a script with the given text that runs in the task's
+// directory and environment, for commands that belong to the task, like its
+// status check.
+func (t *scriptTask) Script(text string) script.Script {
+	return script.Script{Dir: t.script.Dir, Env: t.script.Env, EnvFiles: t.script.EnvFiles, Text: text}
+}
+
 func (t *scriptTask) Metadata() TaskMetadata {
 	return t.metadata
 }
@@ -61,7 +133,10 @@ func (t *scriptTask) Start(ctx context.Context, onReady chan<- struct{}, stdout
 		close(onReady)
 	}
 
-	err := t.script.Start(ctx, stdout, stdout)
+	s := t.script
+	s.Args = Args(ctx)
+	s.ChangedFiles = ChangedFiles(ctx)
+	err := s.Start(ctx, stdout, stdout)
 
 	// For short tasks, signal readiness on successful exit.
 	if t.metadata.Type != "long" && err == nil {
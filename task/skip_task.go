@@ -8,9 +8,13 @@ import (
 // SkipTask wraps an existing Task, replacing its Start behavior with a stub
 // that prints "skipping" and either exits immediately (short) or blocks until
 // context cancellation (long). The original task's metadata is preserved so
-// that the dependency graph remains valid.
+// that the dependency graph remains valid, except for its readiness and
+// liveness probes, which the stub could never pass.
 func SkipTask(original Task) Task {
-	return &skipTask{metadata: original.Metadata()}
+	metadata := original.Metadata()
+	metadata.Ready = nil
+	metadata.Liveness = nil
+	return &skipTask{metadata: metadata}
 }
 
 type skipTask struct {
@@ -3,6 +3,7 @@ package task
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // Anything implementing Task can be run by bundling it into a [Library] and then
@@ -83,6 +84,25 @@ type TaskMetadata struct {
 	// It is invalid to use a "long" task as a trigger.
 	Triggers []string
 
+	// Before, After, and Finally are hooks: IDs of "short" tasks that are
+	// run, one after another, around each run of this task. The hook tasks'
+	// commands run as part of this task, rather than being scheduled by the
+	// run, so their output goes to this task's stream, they have no status
+	// of their own, and they count against concurrency and resource limits
+	// only as this task does. It is invalid for a hook task to have
+	// dependencies or hooks of its own.
+	//   - Before hooks run before the task starts. If one fails, the task
+	//     fails without starting.
+	//   - After hooks run once the task exits successfully. If one fails,
+	//     the task fails.
+	//   - Finally hooks run once the task exits, whether it succeeds,
+	//     fails, or is canceled, even as the run shuts down. If one fails,
+	//     the failure is reported, but it only fails the task if the task
+	//     would otherwise have succeeded.
+	Before  []string
+	After   []string
+	Finally []string
+
 	// Watch specifies file paths where, if a change to
 	// the file path is detected, we should restart the
 	// task. Watch supports globs, and does **not**
@@ -100,4 +120,131 @@ type TaskMetadata struct {
 	//  - `"./src/website/**/*.js"` watches for changes
 	//    to javascript files within src/website.
 	Watch []string
+
+	// WatchIgnore lists patterns, in .gitignore syntax, for paths whose
+	// changes don't restart the task, even though it watches them, like
+	// "node_modules" or "dist/**". Patterns with a slash are relative to
+	// the working directory, like Watch paths. See [watcher.Options].
+	//
+	// If WatchGitignore is set, changes to paths that .gitignore or
+	// .ignore files ignore, or that are in .git directories, don't restart
+	// the task either.
+	WatchIgnore    []string
+	WatchGitignore bool
+
+	// WatchEvents limits the kinds of changes to watched paths that
+	// restart the task to some of "create", "write", "remove", and
+	// "rename". If it's empty, every kind of change does.
+	//
+	// If WatchHash is set, a change to a file doesn't restart the task if
+	// the file's contents are the same as when it last restarted the task,
+	// or as when the task first started, as when an editor saves a file
+	// without changing it or only changes its permissions.
+	WatchEvents []string
+	WatchHash   bool
+
+	// Debounce is how long the task waits, after a change to a path it
+	// watches, for changes to stop before it reruns, so that a burst of
+	// changes, like a "git checkout", reruns it once. Throttle is the
+	// minimum time between its reruns due to watched changes. If either
+	// is zero, the run's default applies: 500 milliseconds of debounce,
+	// and no throttle.
+	Debounce time.Duration
+	Throttle time.Duration
+
+	// Ready optionally specifies a probe that decides when a "long" task
+	// is ready. Without one, a long task is ready as soon as it closes its
+	// onReady channel, which script tasks do as soon as their process
+	// starts. With one, the runner also waits for the probe to pass before
+	// starting the task's dependents.
+	//
+	// It is invalid to give a "short" task a readiness probe.
+	Ready *Probe
+
+	// Liveness optionally specifies a probe that the runner evaluates
+	// periodically once a "long" task is ready. If it fails
+	// FailureThreshold times in a row, the runner marks the task unhealthy
+	// and restarts it as though it had failed, following its Restart policy.
+	//
+	// It is invalid to give a "short" task a liveness probe.
+	Liveness *Probe
+
+	// Restart specifies whether, in a long-running [runner.Run], the task
+	// is restarted after it exits:
+	//   - "always" restarts the task whenever it exits. It is only valid
+	//     for "long" tasks.
+	//   - "on-failure" restarts the task only if it fails.
+	//   - "never" leaves the task stopped once it exits. It can still be
+	//     restarted by a watched file changing or by a trigger.
+	//
+	// If Restart is empty, the run's default applies, which is "always"
+	// for long tasks and "on-failure" for short tasks.
+	Restart string
+
+	// MaxRestarts limits how many times in a row a failing task is
+	// restarted. Once the limit is exceeded, the task is considered
+	// crash-looping and is left stopped until something invalidates it.
+	// Failures stop counting as in a row once the task stays up for longer
+	// than BackoffMax after becoming ready. If MaxRestarts is zero, the
+	// run's default applies, which is no limit.
+	MaxRestarts int
+
+	// BackoffInitial is the delay before restarting a task after its first
+	// failure. The delay doubles with each consecutive failure, up to
+	// BackoffMax. If either is zero, the run's default applies: 1 second
+	// and 30 seconds, respectively.
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// Retries is how many more times a "short" task is attempted, in a
+	// short [runner.Run], after it fails, before the failure is treated
+	// as final. RetryDelay is the delay before each retry; if it's zero,
+	// retries start right away. Long runs restart failed tasks according
+	// to Restart instead.
+	//
+	// It is invalid to give a "long" task retries.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Timeout limits how long a "short" task may run. If the task is still
+	// running after Timeout, the runner cancels it and it fails with a
+	// timeout error. If Timeout is zero, there is no limit.
+	//
+	// It is invalid to give a "long" task a timeout.
+	Timeout time.Duration
+
+	// Sources and Outputs let a "short" task be skipped when it's up to
+	// date. Each is a list of paths, which can use globs, as in
+	// "src/**/*.go". If a run has somewhere to keep state, it records a
+	// fingerprint of the task's sources after each success, and skips the
+	// task next time if the fingerprint hasn't changed and every output
+	// pattern matches at least one file.
+	//
+	// It is invalid to give a "long" task sources or outputs, or to give
+	// any task outputs without sources.
+	Sources []string
+	Outputs []string
+
+	// Status optionally specifies a bash script that decides whether a
+	// "short" task needs to run, for tasks whose freshness can't be
+	// expressed as Sources, like "docker image inspect app". If it exits
+	// 0, the task is considered done without being started. It runs in
+	// the task's directory and, for script tasks, its environment.
+	//
+	// It is invalid to give a "long" task a status script.
+	Status string
+
+	// Resources optionally names pools, like "db", that a "short" task
+	// uses while it runs. A run limits how many tasks may use each pool at
+	// once, so that tasks sharing something like a database or a port
+	// take turns.
+	//
+	// It is invalid to give a "long" task resources.
+	Resources []string
+
+	// Matrix, if set, lists the values that the task was expanded over,
+	// by name. A taskfile task with a matrix becomes one task for each
+	// combination of values, plus a task with the original ID, carrying
+	// the Matrix, that depends on all of them.
+	Matrix map[string][]string
 }
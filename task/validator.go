@@ -5,10 +5,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"unicode"
+
+	"monks.co/run/internal/watcher"
 )
 
+// A ValidationError describes the problems that make a [Library] invalid.
+// Each problem is a sentence describing a single issue with a single task.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{"invalid taskfile"}
+	for _, p := range e.Problems {
+		lines = append(lines, "- "+p)
+	}
+	return strings.Join(lines, "\n")
+}
+
 type validator struct{ cwd string }
 
 func newValidator() validator {
@@ -30,18 +47,18 @@ func (v validator) validate(ts Library) error {
 	for _, id := range ts.IDs() {
 		t := ts.Get(id)
 		if id != t.Metadata().ID {
-			problems = append(problems, fmt.Sprintf("- task '%s' has mismatched key '%s'", t.Metadata().ID, id))
+			problems = append(problems, fmt.Sprintf("task '%s' has mismatched key '%s'", t.Metadata().ID, id))
 		}
 		ids[t.Metadata().ID] = struct{}{}
 	}
 	for _, id := range ts.IDs() {
 		t := ts.Get(id)
 		for _, err := range v.validateTask(ts, t) {
-			problems = append(problems, "- "+err.Error())
+			problems = append(problems, err.Error())
 		}
 	}
 	if len(problems) != 0 {
-		return errors.New(strings.Join(append([]string{"invalid taskfile"}, problems...), "\n"))
+		return &ValidationError{Problems: problems}
 	}
 	return nil
 }
@@ -86,6 +103,101 @@ func (v validator) validateTask(ts Library, t Task) []error {
 		}
 	}
 
+	for _, hook := range []struct {
+		kind string
+		ids  []string
+	}{{"before", meta.Before}, {"after", meta.After}, {"finally", meta.Finally}} {
+		for _, id := range hook.ids {
+			if !ts.Has(id) {
+				problems = append(problems, fmt.Errorf("Task '%s' lists %s hook '%s', which is not the ID of a task.", meta.ID, hook.kind, id))
+			} else if hm := ts.Get(id).Metadata(); hm.Type != "short" {
+				problems = append(problems, fmt.Errorf("Task '%s' lists %s hook '%s', which is long. Only short tasks can be hooks.", meta.ID, hook.kind, id))
+			} else if len(hm.Dependencies) > 0 {
+				problems = append(problems, fmt.Errorf("Task '%s' lists %s hook '%s', which has dependencies. Hooks run without their dependencies, so they can't have any.", meta.ID, hook.kind, id))
+			} else if len(hm.Before)+len(hm.After)+len(hm.Finally) > 0 {
+				problems = append(problems, fmt.Errorf("Task '%s' lists %s hook '%s', which has hooks. Hooks run without their own hooks, so they can't have any.", meta.ID, hook.kind, id))
+			}
+		}
+	}
+
+	if meta.Ready != nil {
+		if meta.Type != "long" {
+			problems = append(problems, fmt.Errorf("Task '%s' has a readiness probe, but only long tasks can have readiness probes.", meta.ID))
+		}
+		for _, err := range validateProbe(*meta.Ready) {
+			problems = append(problems, fmt.Errorf("Task '%s' has an invalid readiness probe: %s", meta.ID, err))
+		}
+	}
+
+	if meta.Liveness != nil {
+		if meta.Type != "long" {
+			problems = append(problems, fmt.Errorf("Task '%s' has a liveness probe, but only long tasks can have liveness probes.", meta.ID))
+		}
+		for _, err := range validateProbe(*meta.Liveness) {
+			problems = append(problems, fmt.Errorf("Task '%s' has an invalid liveness probe: %s", meta.ID, err))
+		}
+	}
+
+	switch meta.Restart {
+	case "", "on-failure", "never":
+	case "always":
+		if meta.Type != "long" {
+			problems = append(problems, fmt.Errorf("Task '%s' has restart policy 'always', but only long tasks can always restart.", meta.ID))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("Task '%s' has invalid restart policy '%s'; must be 'always', 'on-failure', or 'never'.", meta.ID, meta.Restart))
+	}
+	if meta.MaxRestarts < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has negative max_restarts.", meta.ID))
+	}
+	if meta.BackoffInitial < 0 || meta.BackoffMax < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative backoff.", meta.ID))
+	}
+	if meta.BackoffInitial > 0 && meta.BackoffMax > 0 && meta.BackoffMax < meta.BackoffInitial {
+		problems = append(problems, fmt.Errorf("Task '%s' has backoff_max %s, which is less than its backoff_initial %s.", meta.ID, meta.BackoffMax, meta.BackoffInitial))
+	}
+
+	if meta.Retries < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has negative retries.", meta.ID))
+	}
+	if meta.RetryDelay < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative retry_delay.", meta.ID))
+	}
+	if meta.Retries > 0 && meta.Type != "short" {
+		problems = append(problems, fmt.Errorf("Task '%s' has retries, but only short tasks can have retries.", meta.ID))
+	}
+
+	if meta.Debounce < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative debounce.", meta.ID))
+	}
+	if meta.Throttle < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative throttle.", meta.ID))
+	}
+
+	if meta.Timeout < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative timeout.", meta.ID))
+	}
+	if meta.Timeout > 0 && meta.Type != "short" {
+		problems = append(problems, fmt.Errorf("Task '%s' has a timeout, but only short tasks can have timeouts.", meta.ID))
+	}
+
+	if len(meta.Sources) > 0 && meta.Type != "short" {
+		problems = append(problems, fmt.Errorf("Task '%s' has sources, but only short tasks can have sources.", meta.ID))
+	}
+	if meta.Status != "" && meta.Type != "short" {
+		problems = append(problems, fmt.Errorf("Task '%s' has a status command, but only short tasks can have status commands.", meta.ID))
+	}
+	if len(meta.Resources) > 0 && meta.Type != "short" {
+		problems = append(problems, fmt.Errorf("Task '%s' uses resources, but only short tasks can use resources.", meta.ID))
+	}
+	if len(meta.Outputs) > 0 && len(meta.Sources) == 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has outputs, but no sources.", meta.ID))
+	}
+
+	if s, isScript := t.(*scriptTask); isScript && s.script.StopTimeout < 0 {
+		problems = append(problems, fmt.Errorf("Task '%s' has a negative stop_timeout.", meta.ID))
+	}
+
 	for _, path := range meta.Watch {
 		if strings.HasPrefix(path, string(os.PathSeparator)) {
 			problems = append(problems, fmt.Errorf("Task '%s' wants to watch path '%s', which is absolute.", meta.ID, path))
@@ -99,5 +211,46 @@ func (v validator) validateTask(ts Library, t Task) []error {
 		}
 	}
 
+	for _, ev := range meta.WatchEvents {
+		switch ev {
+		case "create", "write", "remove", "rename":
+		default:
+			problems = append(problems, fmt.Errorf("Task '%s' has invalid watch event '%s'; must be 'create', 'write', 'remove', or 'rename'.", meta.ID, ev))
+		}
+	}
+	for _, pattern := range meta.WatchIgnore {
+		if err := watcher.ValidateIgnore(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("Task '%s' has an invalid watch ignore pattern '%s': %s.", meta.ID, pattern, err))
+		}
+	}
+
+	return problems
+}
+
+func validateProbe(p Probe) []error {
+	var problems []error
+	kinds := 0
+	for _, s := range []string{p.HTTP, p.TCP, p.Log, p.Cmd} {
+		if s != "" {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		problems = append(problems, errors.New("exactly one of http, tcp, log, or cmd must be set."))
+	}
+	if p.Log != "" {
+		if _, err := regexp.Compile(p.Log); err != nil {
+			problems = append(problems, fmt.Errorf("log pattern '%s' is not a valid regular expression: %s.", p.Log, err))
+		}
+	}
+	if p.Interval < 0 {
+		problems = append(problems, errors.New("interval cannot be negative."))
+	}
+	if p.Timeout < 0 {
+		problems = append(problems, errors.New("timeout cannot be negative."))
+	}
+	if p.FailureThreshold < 0 {
+		problems = append(problems, errors.New("failure threshold cannot be negative."))
+	}
 	return problems
 }
@@ -0,0 +1,112 @@
+package taskfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An include is an entry in a taskfile's include list. It can be written as a
+// path, like "tools/lint", or as a table with a namespace for the included
+// tasks' IDs, like { path = "services/*", as = "svc" }.
+type include struct {
+	// Path is the directory of a child taskfile, relative to the
+	// including taskfile. It can be a glob, like "services/*", to include
+	// every matching directory that contains a tasks.toml.
+	Path string
+
+	// As optionally replaces the non-glob part of Path in the included
+	// tasks' IDs. With { path = "services/*", as = "svc" }, the task
+	// "build" in services/api/tasks.toml has the ID "svc/api/build".
+	As string
+}
+
+func (inc *include) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		inc.Path = v
+	case map[string]any:
+		for k, val := range v {
+			s, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("include %s must be a string", k)
+			}
+			switch k {
+			case "path":
+				inc.Path = s
+			case "as":
+				inc.As = s
+			default:
+				return fmt.Errorf("unknown include field '%s'", k)
+			}
+		}
+	default:
+		return fmt.Errorf("include must be a path or a table, not %T", v)
+	}
+	if inc.Path == "" {
+		return fmt.Errorf("include has no path")
+	}
+	if strings.Contains(inc.As, "/") {
+		return fmt.Errorf("include namespace '%s' cannot contain '/'", inc.As)
+	}
+	return nil
+}
+
+// match finds the taskfile directories that inc includes, from the taskfile
+// in dir, whose tasks' IDs start with prefix. It returns a [dir, prefix]
+// pair for each one.
+func (inc include) match(cwd, dir, prefix string) ([][2]string, error) {
+	base := filepath.Join(cwd, dir)
+	var paths []string
+	if staticPrefix(inc.Path) == filepath.Clean(inc.Path) {
+		paths = []string{filepath.Join(base, inc.Path)}
+	} else {
+		globbed, err := filepath.Glob(filepath.Join(base, inc.Path))
+		if err != nil {
+			return nil, fmt.Errorf("include '%s': %w", inc.Path, err)
+		}
+		for _, p := range globbed {
+			if _, err := os.Stat(filepath.Join(p, "tasks.toml")); err == nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	var out [][2]string
+	for _, p := range paths {
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return nil, err
+		}
+		if rel == "." || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("include '%s' is not a child directory", inc.Path)
+		}
+		id := filepath.Join(prefix, rel)
+		if inc.As != "" {
+			rest, err := filepath.Rel(filepath.Join(base, staticPrefix(inc.Path)), p)
+			if err != nil {
+				return nil, err
+			}
+			id = filepath.Join(prefix, inc.As, rest)
+		}
+		out = append(out, [2]string{filepath.Join(dir, rel), id})
+	}
+	return out, nil
+}
+
+// staticPrefix returns the leading path elements of pattern that contain no
+// glob metacharacters.
+func staticPrefix(pattern string) string {
+	var static []string
+	for _, elem := range strings.Split(filepath.Clean(pattern), string(os.PathSeparator)) {
+		if strings.ContainsAny(elem, `*?[\`) {
+			break
+		}
+		static = append(static, elem)
+	}
+	if len(static) == 0 {
+		return "."
+	}
+	return filepath.Join(static...)
+}
@@ -0,0 +1,108 @@
+package taskfile
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// expand returns the tasks that t stands for. If t has no matrix, that's
+// just t. Otherwise, it's one task for each combination of t's matrix values,
+// with an ID like "test[os=linux,service=api]", followed by a task with t's
+// own ID that depends on all of them.
+//
+// Each expansion's matrix values are set as variables, which take precedence
+// over any others, and as environment variables, unless t's env already sets
+// them.
+func (t taskfileTask) expand() ([]taskfileTask, []string) {
+	if len(t.Matrix) == 0 {
+		return []taskfileTask{t}, nil
+	}
+
+	var problems []string
+	keys := slices.Sorted(maps.Keys(t.Matrix))
+	for _, k := range keys {
+		if !isVarName(k) {
+			problems = append(problems, fmt.Sprintf("Task '%s' has invalid matrix key '%s'.", t.ID, k))
+		}
+		if len(t.Matrix[k]) == 0 {
+			problems = append(problems, fmt.Sprintf("Task '%s' has no values for matrix key '%s'.", t.ID, k))
+		}
+		for _, v := range t.Matrix[k] {
+			if v == "" || strings.ContainsFunc(v, isReservedMatrixRune) {
+				problems = append(problems, fmt.Sprintf("Task '%s' has invalid value '%s' for matrix key '%s'.", t.ID, v, k))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	combinations := []map[string]string{{}}
+	for _, k := range keys {
+		var next []map[string]string
+		for _, c := range combinations {
+			for _, v := range t.Matrix[k] {
+				c := maps.Clone(c)
+				c[k] = v
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	group := taskfileTask{
+		ID:          t.ID,
+		Description: t.Description,
+		Type:        t.Type,
+		Matrix:      t.Matrix,
+	}
+	var expansions []taskfileTask
+	for _, c := range combinations {
+		e := t
+		e.Matrix = nil
+		e.matrix = c
+		e.ID = matrixID(t.ID, keys, c)
+		e.Env = maps.Clone(t.Env)
+		if e.Env == nil {
+			e.Env = map[string]string{}
+		}
+		for k, v := range c {
+			if _, ok := e.Env[k]; !ok {
+				e.Env[k] = v
+			}
+		}
+		e.Dependencies = slices.Clone(t.Dependencies)
+		e.Triggers = slices.Clone(t.Triggers)
+		e.Before = slices.Clone(t.Before)
+		e.After = slices.Clone(t.After)
+		e.Finally = slices.Clone(t.Finally)
+		e.Watch = slices.Clone(t.Watch)
+		e.WatchIgnore = slices.Clone(t.WatchIgnore)
+		e.WatchEvents = slices.Clone(t.WatchEvents)
+		e.Sources = slices.Clone(t.Sources)
+		e.Outputs = slices.Clone(t.Outputs)
+		e.EnvFile = slices.Clone(t.EnvFile)
+		expansions = append(expansions, e)
+		group.Dependencies = append(group.Dependencies, e.ID)
+	}
+	return append([]taskfileTask{group}, expansions...), nil
+}
+
+// matrixID returns the ID of the expansion of the task with the given ID for
+// the matrix values c, as in "test[os=linux,service=api]".
+func matrixID(id string, keys []string, c map[string]string) string {
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + c[k]
+	}
+	return id + "[" + strings.Join(pairs, ",") + "]"
+}
+
+// isReservedMatrixRune reports whether r can't be used in a matrix value,
+// since it would make the expansions' IDs ambiguous or invalid.
+func isReservedMatrixRune(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("/@[],=", r)
+}
@@ -0,0 +1,78 @@
+package taskfile
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"monks.co/run/task"
+)
+
+// Settings are the options for a whole run that a root taskfile can set,
+// as opposed to the options for its tasks.
+type Settings struct {
+	// Concurrency limits how many short tasks may run at once. If it's 0,
+	// there is no limit.
+	Concurrency int
+
+	// Resources limits how many tasks may use each named resource at once.
+	// Tasks list the resources they use; a resource that isn't listed here
+	// may be used by one task at a time.
+	Resources map[string]int
+
+	// OnExit lists the IDs of tasks to run once the run is over, as in
+	// cleanup that must happen even if the run fails or is canceled.
+	OnExit []string
+
+	// Debounce and Throttle are the defaults for tasks that don't set
+	// their own; see [task.TaskMetadata]. If they're 0, the run's defaults
+	// apply.
+	Debounce time.Duration
+	Throttle time.Duration
+}
+
+// LoadSettings loads the run settings from the taskfile in cwd. Problems with
+// the settings are reported in a [*task.ValidationError].
+func LoadSettings(cwd string) (Settings, error) {
+	parsed, err := load(cwd, ".")
+	if err != nil {
+		return Settings{}, err
+	}
+	root, err := workspaceRoot(cwd)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	var problems []string
+	if parsed.Concurrency < 0 {
+		problems = append(problems, "The taskfile has a negative concurrency.")
+	}
+	if parsed.Debounce < 0 {
+		problems = append(problems, "The taskfile has a negative debounce.")
+	}
+	if parsed.Throttle < 0 {
+		problems = append(problems, "The taskfile has a negative throttle.")
+	}
+	for _, name := range slices.Sorted(maps.Keys(parsed.Resources)) {
+		if parsed.Resources[name] < 1 {
+			problems = append(problems, fmt.Sprintf("Resource '%s' has a limit of %d, but limits must be at least 1.", name, parsed.Resources[name]))
+		}
+	}
+	if len(problems) > 0 {
+		return Settings{}, &task.ValidationError{Problems: problems}
+	}
+
+	var onExit []string
+	for _, ref := range parsed.OnExit {
+		onExit = append(onExit, resolveRef(cwd, ".", root, ref))
+	}
+
+	return Settings{
+		Concurrency: parsed.Concurrency,
+		Resources:   parsed.Resources,
+		OnExit:      onExit,
+		Debounce:    parsed.Debounce,
+		Throttle:    parsed.Throttle,
+	}, nil
+}
@@ -2,12 +2,17 @@
 package taskfile
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"monks.co/run/internal/watcher"
 	"monks.co/run/task"
 )
 
@@ -16,12 +21,54 @@ import (
 // target whose path contains a "/", even if no existing task references it.
 // This allows running tasks in nested directories that aren't referenced by
 // the root taskfile.
+//
+// Load also loads any child taskfiles that a taskfile includes with its
+// include list, and any that a task refers to.
 func Load(cwd string, targetTaskIDs ...string) (task.Library, error) {
+	return LoadWithVars(cwd, nil, targetTaskIDs...)
+}
+
+// LoadWithVars is like [Load], but it also sets the given variables, which
+// take precedence over any set by the taskfiles' vars tables. References to
+// undefined variables are reported as problems in a [*task.ValidationError],
+// along with any other problems with the tasks.
+func LoadWithVars(cwd string, vars map[string]string, targetTaskIDs ...string) (task.Library, error) {
 	var allTasks []task.Task
+	var problems []string
+
+	root, err := workspaceRoot(cwd)
+	if err != nil {
+		return task.Library{}, err
+	}
+	absCWD, err := filepath.Abs(cwd)
+	if err != nil {
+		return task.Library{}, err
+	}
+	outside := func(ref string) bool {
+		rel, err := filepath.Rel(filepath.Join(absCWD, root), filepath.Join(absCWD, ref))
+		return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+	}
 
 	seenDirs := map[string]struct{}{}
-	var ingestTaskMap func(dir string) error
-	ingestTaskMap = func(dir string) error {
+
+	// namespaces maps the ID prefixes given to included taskfiles with
+	// "as" to the directories they stand for.
+	namespaces := map[string]string{}
+
+	// resolve returns the directory of the taskfile whose tasks have IDs
+	// starting with prefix.
+	resolve := func(prefix string) string {
+		for p := prefix; p != "." && p != string(os.PathSeparator); p = filepath.Dir(p) {
+			if dir, ok := namespaces[p]; ok {
+				rest, _ := filepath.Rel(p, prefix)
+				return filepath.Join(dir, rest)
+			}
+		}
+		return prefix
+	}
+
+	var ingestTaskMap func(dir, prefix string) error
+	ingestTaskMap = func(dir, prefix string) error {
 		if _, ok := seenDirs[dir]; ok {
 			return nil
 		}
@@ -32,39 +79,83 @@ func Load(cwd string, targetTaskIDs ...string) (task.Library, error) {
 			relativeDir = "."
 		}
 
-		theseTasks, err := load(cwd, dir)
+		parsed, err := load(cwd, dir)
 		if err != nil {
 			return err
 		}
+
+		// Find included taskfiles first, so that references to their
+		// namespaces can be resolved.
+		var included [][2]string
+		for _, inc := range parsed.Include {
+			matches, err := inc.match(cwd, relativeDir, prefix)
+			if err != nil {
+				return err
+			}
+			included = append(included, matches...)
+			if inc.As != "" {
+				namespaces[filepath.Join(prefix, inc.As)] = filepath.Join(relativeDir, staticPrefix(inc.Path))
+			}
+		}
+
+		var expanded []taskfileTask
+		for _, t := range parsed.Tasks {
+			ts, matrixProblems := t.expand()
+			problems = append(problems, matrixProblems...)
+			expanded = append(expanded, ts...)
+		}
+
 		depSet := map[string]struct{}{}
-		for _, t := range theseTasks {
-			t := t.withDir(cwd, relativeDir)
+		for _, t := range expanded {
+			t.EnvFile = slices.Concat(parsed.EnvFile, t.EnvFile)
+			t.WatchIgnore = slices.Concat(parsed.WatchIgnore, t.WatchIgnore)
+			t.WatchGitignore = t.WatchGitignore || parsed.WatchGitignore
+			t, varProblems := t.interpolate(parsed.Vars, vars)
+			problems = append(problems, varProblems...)
+			t = t.withDir(cwd, relativeDir, prefix, root)
 
+			if _, ok := parseSignal(t.StopSignal); t.StopSignal != "" && !ok {
+				problems = append(problems, fmt.Sprintf("Task '%s' has unknown stop_signal '%s'.", t.ID, t.StopSignal))
+			}
 			allTasks = append(allTasks, t.toScriptTask())
 			for _, dep := range t.Dependencies {
-				if strings.Contains(dep, "/") {
+				if outside(dep) {
+					problems = append(problems, fmt.Sprintf("Task '%s' lists dependency '%s', which is outside of the workspace.", t.ID, dep))
+				} else if strings.Contains(dep, "/") {
 					depSet[dep] = struct{}{}
 				}
 			}
 			for _, dep := range t.Triggers {
-				if strings.Contains(dep, "/") {
+				if outside(dep) {
+					problems = append(problems, fmt.Sprintf("Task '%s' lists trigger '%s', which is outside of the workspace.", t.ID, dep))
+				} else if strings.Contains(dep, "/") {
 					depSet[dep] = struct{}{}
 				}
 			}
+			for _, hook := range slices.Concat(t.Before, t.After, t.Finally) {
+				if outside(hook) {
+					problems = append(problems, fmt.Sprintf("Task '%s' lists hook '%s', which is outside of the workspace.", t.ID, hook))
+				} else if strings.Contains(hook, "/") {
+					depSet[hook] = struct{}{}
+				}
+			}
+		}
+
+		for _, inc := range included {
+			if err := ingestTaskMap(inc[0], inc[1]); err != nil {
+				return err
+			}
 		}
 
 		// Reload referenced taskfiles (if there are any)
 		for id := range depSet {
 			p := filepath.Dir(id)
-			if p == relativeDir {
+			if p == prefix {
 				continue
 			}
 			// ignore the task ID and just load the whole
 			// referenced taskfile
-
-			p = strings.TrimPrefix(p, relativeDir+string(os.PathSeparator))
-			p = filepath.Join(dir, p)
-			if err := ingestTaskMap(p); err != nil {
+			if err := ingestTaskMap(resolve(p), p); err != nil {
 				return err
 			}
 		}
@@ -72,16 +163,16 @@ func Load(cwd string, targetTaskIDs ...string) (task.Library, error) {
 		return nil
 	}
 
-	if err := ingestTaskMap("."); err != nil {
+	if err := ingestTaskMap(".", "."); err != nil {
 		return task.Library{}, err
 	}
 
 	for _, id := range targetTaskIDs {
-		if !strings.Contains(id, "/") {
+		if !strings.Contains(id, "/") || outside(id) {
 			continue
 		}
-		dir := filepath.Dir(id)
-		if err := ingestTaskMap(dir); err != nil {
+		prefix := filepath.Dir(id)
+		if err := ingestTaskMap(resolve(prefix), prefix); err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
@@ -91,26 +182,62 @@ func Load(cwd string, targetTaskIDs ...string) (task.Library, error) {
 
 	tf := task.NewLibrary(allTasks...)
 
-	if err := tf.ValidateWithCWD(cwd); err != nil {
+	var validationErr *task.ValidationError
+	if err := tf.ValidateWithCWD(filepath.Join(cwd, root)); errors.As(err, &validationErr) {
+		validationErr.Problems = append(problems, validationErr.Problems...)
+		return task.Library{}, validationErr
+	} else if err != nil {
 		return task.Library{}, err
+	} else if len(problems) > 0 {
+		return task.Library{}, &task.ValidationError{Problems: problems}
 	}
 
 	return tf, nil
 }
 
-func load(cwd, dir string) ([]taskfileTask, error) {
+func load(cwd, dir string) (taskfile, error) {
 	f, err := os.ReadFile(filepath.Join(cwd, dir, "tasks.toml"))
 	if err != nil {
-		return nil, err
+		return taskfile{}, err
 	}
 	var parsed taskfile
 	if err := toml.Unmarshal(f, &parsed); err != nil {
-		return nil, err
+		return taskfile{}, err
 	}
-	return parsed.Tasks, nil
+	return parsed, nil
 }
 
 type taskfile struct {
+	// Workspace marks the taskfile's directory as the root of a workspace.
+	// Tasks in the workspace can refer to each other with references like
+	// "../shared/build", or, relative to the root, "//tools/codegen".
+	Workspace bool `toml:"workspace"`
+
+	// Vars are variables that the taskfile's tasks can refer to, as in
+	// "${NAME}".
+	Vars map[string]string `toml:"vars"`
+
+	// EnvFile lists .env files whose variables are set for every task in
+	// the taskfile.
+	EnvFile []string `toml:"env_file"`
+
+	// Include lists child taskfiles to load, whether or not any task
+	// refers to them.
+	Include []include `toml:"include"`
+
+	// WatchIgnore and WatchGitignore are defaults for the taskfile's
+	// tasks. WatchIgnore's patterns are added before each task's own.
+	WatchIgnore    []string `toml:"watch_ignore"`
+	WatchGitignore bool     `toml:"watch_gitignore"`
+
+	// Concurrency, Resources, OnExit, Debounce, and Throttle are run
+	// settings; see [Settings].
+	Concurrency int            `toml:"concurrency"`
+	Resources   map[string]int `toml:"resources"`
+	OnExit      []string       `toml:"on_exit"`
+	Debounce    time.Duration  `toml:"debounce"`
+	Throttle    time.Duration  `toml:"throttle"`
+
 	Tasks []taskfileTask `toml:"task"`
 }
 
@@ -122,33 +249,211 @@ type taskfileTask struct {
 	Triggers     []string `toml:"triggers"`
 	Watch        []string `toml:"watch"`
 
+	// Before, After, and Finally list hook tasks to run around the task.
+	// See [task.TaskMetadata].
+	Before  []string `toml:"before"`
+	After   []string `toml:"after"`
+	Finally []string `toml:"finally"`
+
+	// Sources and Outputs are paths, relative to the taskfile, that decide
+	// whether the task is up to date. See [task.TaskMetadata].
+	Sources []string `toml:"sources"`
+	Outputs []string `toml:"outputs"`
+
+	// Vars are variables that only this task can refer to. They take
+	// precedence over the taskfile's vars.
+	Vars map[string]string `toml:"vars"`
+
+	// Matrix expands the task into one task per combination of its
+	// values. See [taskfileTask.expand].
+	Matrix map[string][]string `toml:"matrix"`
+
+	// WorkDir is the directory CMD runs in, relative to the taskfile's
+	// directory. It defaults to the taskfile's directory.
+	WorkDir string `toml:"dir"`
+
 	// CMD is the command to run. It runs in a new bash process, as in,
 	//     $ bash -c "$CMD"
 	// CMD can have many lines.
 	CMD string `toml:"cmd"`
 
+	// Status is a command that, if it exits 0, means that the task needn't
+	// run. Like CMD, it runs in a new bash process.
+	Status string `toml:"status"`
+
+	// Resources names pools, declared in the root taskfile's resources
+	// table, that the task uses while it runs. See [task.TaskMetadata].
+	Resources []string `toml:"resources"`
+
 	// Env is a map of environment variables that are set for this task's
 	// CMD process.
 	Env map[string]string `toml:"env"`
 
+	// EnvFile lists .env files whose variables are set for this task's
+	// CMD process, after the taskfile's env files and before Env.
+	EnvFile []string `toml:"env_file"`
+
+	// WatchEnvFile restarts the task when any of its env files change.
+	WatchEnvFile bool `toml:"watch_env_file"`
+
+	// WatchIgnore lists patterns for watched paths whose changes don't
+	// restart the task, and WatchGitignore also ignores changes to paths
+	// that git ignores. See [task.TaskMetadata].
+	WatchIgnore    []string `toml:"watch_ignore"`
+	WatchGitignore bool     `toml:"watch_gitignore"`
+
+	// WatchEvents limits the kinds of changes that restart the task, and
+	// WatchHash ignores changes that leave a file's contents the same. See
+	// [task.TaskMetadata].
+	WatchEvents []string `toml:"watch_events"`
+	WatchHash   bool     `toml:"watch_hash"`
+
+	// Debounce and Throttle control how soon the task reruns after changes
+	// to paths it watches. See [task.TaskMetadata].
+	Debounce time.Duration `toml:"debounce"`
+	Throttle time.Duration `toml:"throttle"`
+
+	// Ready is an optional readiness probe for a long task.
+	Ready *taskfileProbe `toml:"ready"`
+
+	// Liveness is an optional liveness probe for a long task.
+	Liveness *taskfileProbe `toml:"liveness"`
+
+	Restart        string        `toml:"restart"`
+	MaxRestarts    int           `toml:"max_restarts"`
+	BackoffInitial time.Duration `toml:"backoff_initial"`
+	BackoffMax     time.Duration `toml:"backoff_max"`
+
+	// Retries and RetryDelay control how a short task is retried after it
+	// fails in a short run.
+	Retries    int           `toml:"retries"`
+	RetryDelay time.Duration `toml:"retry_delay"`
+
+	// Timeout limits how long a short task may run.
+	Timeout time.Duration `toml:"timeout"`
+
+	// StopSignal, StopTimeout, and StopCMD control how the task's CMD
+	// process is stopped. See [task.WithStopSignal], [task.WithStopTimeout],
+	// and [task.WithStopCommand].
+	StopSignal  string        `toml:"stop_signal"`
+	StopTimeout time.Duration `toml:"stop_timeout"`
+	StopCMD     string        `toml:"stop_cmd"`
+
 	dir string
+
+	// matrix holds the matrix values of a task expanded from a matrix.
+	matrix map[string]string
+}
+
+type taskfileProbe struct {
+	HTTP     string        `toml:"http"`
+	TCP      string        `toml:"tcp"`
+	Log      string        `toml:"log"`
+	Cmd      string        `toml:"cmd"`
+	Interval time.Duration `toml:"interval"`
+	Timeout  time.Duration `toml:"timeout"`
+
+	FailureThreshold int `toml:"failure_threshold"`
+}
+
+func (p *taskfileProbe) toProbe() *task.Probe {
+	if p == nil {
+		return nil
+	}
+	return &task.Probe{
+		HTTP:     p.HTTP,
+		TCP:      p.TCP,
+		Log:      p.Log,
+		Cmd:      p.Cmd,
+		Interval: p.Interval,
+		Timeout:  p.Timeout,
+
+		FailureThreshold: p.FailureThreshold,
+	}
 }
 
-func (t taskfileTask) withDir(cwd, dir string) taskfileTask {
-	t.ID = filepath.Join(dir, filepath.FromSlash(t.ID))
+// withDir resolves t's paths relative to dir, its taskfile's directory, and
+// its IDs relative to prefix, which is usually the same as dir but differs
+// for taskfiles included with "as". References starting with "//" are
+// resolved relative to root, the workspace root.
+func (t taskfileTask) withDir(cwd, dir, prefix, root string) taskfileTask {
+	t.ID = filepath.Join(prefix, filepath.FromSlash(t.ID))
 	t.dir = filepath.Join(cwd, dir)
+	if filepath.IsAbs(t.WorkDir) {
+		t.dir = t.WorkDir
+	} else if t.WorkDir != "" {
+		t.dir = filepath.Join(t.dir, t.WorkDir)
+	}
 	for i, dep := range t.Dependencies {
-		t.Dependencies[i] = filepath.Join(dir, dep)
+		t.Dependencies[i] = resolveRef(cwd, prefix, root, dep)
 	}
 	for i, dep := range t.Triggers {
-		t.Triggers[i] = filepath.Join(dir, dep)
+		t.Triggers[i] = resolveRef(cwd, prefix, root, dep)
+	}
+	for _, hooks := range [][]string{t.Before, t.After, t.Finally} {
+		for i, hook := range hooks {
+			hooks[i] = resolveRef(cwd, prefix, root, hook)
+		}
 	}
 	for i, p := range t.Watch {
 		t.Watch[i] = filepath.Join(dir, p)
 	}
+	for i, p := range t.WatchIgnore {
+		t.WatchIgnore[i] = watcher.JoinIgnore(dir, p)
+	}
+	for i, p := range t.Sources {
+		t.Sources[i] = filepath.Join(dir, p)
+	}
+	for i, p := range t.Outputs {
+		t.Outputs[i] = filepath.Join(dir, p)
+	}
+	for i, p := range t.EnvFile {
+		// Watched paths are relative to cwd, even for absolute env files.
+		watchP := filepath.Join(dir, p)
+		if filepath.IsAbs(p) {
+			if abs, err := filepath.Abs(cwd); err == nil {
+				watchP, _ = filepath.Rel(abs, p)
+			}
+		} else {
+			p = filepath.Join(cwd, dir, p)
+		}
+		if t.WatchEnvFile {
+			// A missing file is watched for until it's created, but
+			// only in a directory that exists.
+			if _, err := os.Stat(filepath.Dir(p)); err == nil {
+				t.Watch = append(t.Watch, watchP)
+			}
+		}
+		t.EnvFile[i] = p
+	}
 	return t
 }
 
+// resolveRef resolves a task reference from a taskfile whose tasks' IDs
+// start with prefix, producing a task ID. References like "../shared/build"
+// that leave cwd and come back, as in "../cwd/build", are simplified, so
+// that each task has just one ID.
+func resolveRef(cwd, prefix, root, ref string) string {
+	if strings.HasPrefix(ref, "//") {
+		ref = filepath.Join(root, ref[2:])
+	} else {
+		ref = filepath.Join(prefix, ref)
+	}
+	if !strings.HasPrefix(ref, "..") {
+		return ref
+	}
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		return ref
+	}
+	if rel, err := filepath.Rel(abs, filepath.Join(abs, ref)); err == nil {
+		return rel
+	}
+	return ref
+}
+
+// toScriptTask converts t to a script task. An unknown stop_signal is
+// ignored; Load reports it.
 func (t taskfileTask) toScriptTask() task.Task {
 	description := t.Description
 	if description == "" && t.CMD != "" && !strings.Contains(t.CMD, "\n") {
@@ -158,12 +463,67 @@ func (t taskfileTask) toScriptTask() task.Task {
 	for k, v := range t.Env {
 		env = append(env, k+"="+v)
 	}
+	var opts []task.ScriptOption
+	if sig, ok := parseSignal(t.StopSignal); ok {
+		opts = append(opts, task.WithStopSignal(sig))
+	}
+	if t.StopTimeout != 0 {
+		opts = append(opts, task.WithStopTimeout(t.StopTimeout))
+	}
+	if t.StopCMD != "" {
+		opts = append(opts, task.WithStopCommand(t.StopCMD))
+	}
+	if len(t.EnvFile) > 0 {
+		opts = append(opts, task.WithEnvFiles(t.EnvFile...))
+	}
 	return task.ScriptTask(t.CMD, t.dir, env, task.TaskMetadata{
 		ID:           t.ID,
 		Description:  description,
 		Type:         t.Type,
 		Dependencies: t.Dependencies,
 		Triggers:     t.Triggers,
+		Before:       t.Before,
+		After:        t.After,
+		Finally:      t.Finally,
 		Watch:        t.Watch,
-	})
+		WatchIgnore:  t.WatchIgnore,
+		WatchEvents:  t.WatchEvents,
+		Sources:      t.Sources,
+		Outputs:      t.Outputs,
+		Status:       t.Status,
+		Resources:    t.Resources,
+		Ready:        t.Ready.toProbe(),
+		Liveness:     t.Liveness.toProbe(),
+
+		Restart:        t.Restart,
+		MaxRestarts:    t.MaxRestarts,
+		BackoffInitial: t.BackoffInitial,
+		BackoffMax:     t.BackoffMax,
+		Retries:        t.Retries,
+		RetryDelay:     t.RetryDelay,
+		Timeout:        t.Timeout,
+		WatchGitignore: t.WatchGitignore,
+		WatchHash:      t.WatchHash,
+		Debounce:       t.Debounce,
+		Throttle:       t.Throttle,
+
+		Matrix: t.Matrix,
+	}, opts...)
+}
+
+// signals are the stop_signal values a taskfile can use, by name.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// parseSignal parses a signal name like "SIGTERM" or "term".
+func parseSignal(name string) (syscall.Signal, bool) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	return sig, ok
 }
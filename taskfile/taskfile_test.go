@@ -1,8 +1,13 @@
 package taskfile
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"monks.co/run/task"
 	"github.com/stretchr/testify/assert"
@@ -119,3 +124,343 @@ func testNesting(t *testing.T, ts task.Library) {
 		},
 	}, metas)
 }
+
+func TestLoadReadinessProbe(t *testing.T) {
+	ts, err := Load("./testdata/ready")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &task.Probe{
+		TCP:      ":5432",
+		Interval: 250 * time.Millisecond,
+		Timeout:  30 * time.Second,
+	}, ts.Get("db").Metadata().Ready)
+}
+
+func TestLoadLivenessProbe(t *testing.T) {
+	ts, err := Load("./testdata/liveness")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &task.Probe{
+		HTTP:             "http://localhost:8080/healthz",
+		Interval:         5 * time.Second,
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+	}, ts.Get("server").Metadata().Liveness)
+}
+
+func TestLoadRestartPolicy(t *testing.T) {
+	ts, err := Load("./testdata/restart")
+	assert.NoError(t, err)
+
+	tm := ts.Get("server").Metadata()
+	assert.Equal(t, "on-failure", tm.Restart)
+	assert.Equal(t, 5, tm.MaxRestarts)
+	assert.Equal(t, 500*time.Millisecond, tm.BackoffInitial)
+	assert.Equal(t, time.Minute, tm.BackoffMax)
+}
+
+func TestLoadTimeout(t *testing.T) {
+	ts, err := Load("./testdata/timeout")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 10*time.Minute, ts.Get("test").Metadata().Timeout)
+}
+
+func TestLoadRetries(t *testing.T) {
+	ts, err := Load("./testdata/retries")
+	assert.NoError(t, err)
+
+	meta := ts.Get("integration").Metadata()
+	assert.Equal(t, 2, meta.Retries)
+	assert.Equal(t, 5*time.Second, meta.RetryDelay)
+}
+
+func TestLoadWatchIgnore(t *testing.T) {
+	ts, err := Load("./testdata/watch-ignore")
+	assert.NoError(t, err)
+
+	server := ts.Get("server").Metadata()
+	assert.Equal(t, []string{"*.log", "node_modules", "tmp/"}, server.WatchIgnore)
+	assert.True(t, server.WatchGitignore)
+
+	// Patterns with a slash are relative to their taskfile, and a
+	// taskfile's defaults only apply to its own tasks.
+	bundle := ts.Get("web/bundle").Metadata()
+	assert.Equal(t, []string{"web/dist/**", "!web/src/keep.log"}, bundle.WatchIgnore)
+	assert.False(t, bundle.WatchGitignore)
+}
+
+func TestLoadWatchEvents(t *testing.T) {
+	ts, err := Load("./testdata/watch-events")
+	assert.NoError(t, err)
+
+	meta := ts.Get("dev").Metadata()
+	assert.Equal(t, []string{"create", "write", "remove"}, meta.WatchEvents)
+	assert.True(t, meta.WatchHash)
+	assert.Equal(t, 100*time.Millisecond, meta.Debounce)
+	assert.Zero(t, meta.Throttle)
+
+	settings, err := LoadSettings("./testdata/watch-events")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, settings.Debounce)
+	assert.Equal(t, 5*time.Second, settings.Throttle)
+
+	_, err = Load("./testdata/bad-watch-events")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'dev' has invalid watch event 'chmod'; must be 'create', 'write', 'remove', or 'rename'.\n- Task 'dev' has an invalid watch ignore pattern 'src/[a': unexpected end of input.")
+}
+
+func TestLoadStopSettings(t *testing.T) {
+	ts, err := Load("./testdata/stop")
+	assert.NoError(t, err)
+	assert.True(t, ts.Has("db"))
+	assert.True(t, ts.Has("container"))
+
+	_, err = Load("./testdata/bad-stop-signal")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'db' has unknown stop_signal 'SIGNOPE'.\n- Task 'web' has a negative stop_timeout.")
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGTERM", "TERM", "sigterm", "term"} {
+		sig, ok := parseSignal(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, syscall.SIGTERM, sig, name)
+	}
+	_, ok := parseSignal("SIGNOPE")
+	assert.False(t, ok)
+}
+
+func TestLoadWithVars(t *testing.T) {
+	ts, err := LoadWithVars("./testdata/vars", map[string]string{"TARGET": "build"})
+	assert.NoError(t, err)
+
+	build := ts.Get("build")
+	assert.Equal(t, []string{"src/**"}, build.Metadata().Watch)
+	assert.Equal(t, "testdata/vars/src", build.(dirGetter).Dir())
+
+	test := ts.Get("test")
+	assert.Equal(t, []string{"build"}, test.Metadata().Dependencies)
+	assert.Equal(t, `"go test -o test-build ./... && echo ${UNSET}"`, test.Metadata().Description)
+
+	_, err = Load("./testdata/vars")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'test' uses undefined variable 'TARGET' in dependencies.")
+}
+
+func TestLoadUndefinedVars(t *testing.T) {
+	_, err := Load("./testdata/undefined-vars")
+	var validationErr *task.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"Task 'build' uses undefined variable 'SRC' in watch.",
+		"Task 'build' uses undefined variable 'TARGET' in dependencies.",
+	}, validationErr.Problems)
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"A": "1", "B_2": "two"}
+	for _, tc := range []struct {
+		in, out   string
+		undefined []string
+	}{
+		{"plain", "plain", nil},
+		{"${A}-${B_2}", "1-two", nil},
+		{"$A ${A}", "$A 1", nil},
+		{"$${A}", "${A}", nil},
+		{"${C} ${A}", "${C} 1", []string{"C"}},
+		{"${1:-x} ${A", "${1:-x} ${A", nil},
+	} {
+		out, undefined := interpolate(tc.in, vars)
+		assert.Equal(t, tc.out, out, tc.in)
+		assert.Equal(t, tc.undefined, undefined, tc.in)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	ts, err := Load("./testdata/env-file")
+	assert.NoError(t, err)
+
+	api := ts.Get("api")
+	assert.Equal(t, []string{".env", ".env.local", ".env.missing"}, api.Metadata().Watch)
+
+	var out strings.Builder
+	err = api.Start(context.Background(), make(chan struct{}), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "shared local inline\n", out.String())
+
+	web := ts.Get("web")
+	assert.Equal(t, []string{".env", "secrets/.env"}, web.Metadata().Watch)
+	out.Reset()
+	err = web.Start(context.Background(), make(chan struct{}), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "shared secret\n", out.String())
+
+	// Env files can be absolute.
+	secrets, err := filepath.Abs("testdata/env-file/secrets")
+	assert.NoError(t, err)
+	ts, err = LoadWithVars("./testdata/env-file", map[string]string{"SECRETS": secrets})
+	assert.NoError(t, err)
+	web = ts.Get("web")
+	assert.Equal(t, []string{".env", "secrets/.env"}, web.Metadata().Watch)
+	out.Reset()
+	err = web.Start(context.Background(), make(chan struct{}), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "shared secret\n", out.String())
+
+	// Env files in directories that don't exist aren't watched.
+	ts, err = LoadWithVars("./testdata/env-file", map[string]string{"SECRETS": "nope"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".env"}, ts.Get("web").Metadata().Watch)
+}
+
+func TestLoadInclude(t *testing.T) {
+	ts, err := Load("./testdata/include")
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"deploy",
+		"tools/lint/lint",
+		"svc/api/build",
+		"svc/api/test",
+		"svc/web/build",
+	}, ts.IDs())
+	assert.Equal(t, []string{"svc/api/build"}, ts.Get("svc/api/test").Metadata().Dependencies)
+	assert.Equal(t, "testdata/include/services/api", ts.Get("svc/api/build").(dirGetter).Dir())
+}
+
+func TestLoadIncludedTarget(t *testing.T) {
+	// Targets can refer to included tasks by their namespaced IDs.
+	ts, err := Load("./testdata/include", "svc/web/build")
+	assert.NoError(t, err)
+	assert.True(t, ts.Has("svc/web/build"))
+}
+
+func TestFind(t *testing.T) {
+	dir, err := Find("./testdata/workspace/app/src")
+	assert.NoError(t, err)
+	assert.Equal(t, "testdata/workspace/app", dir)
+
+	dir, err = Find("./testdata/workspace/app")
+	assert.NoError(t, err)
+	assert.Equal(t, "./testdata/workspace/app", dir)
+}
+
+func TestLoadWorkspaceReferences(t *testing.T) {
+	ts, err := Load("./testdata/workspace/app")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"../shared/build",
+		"../tools/codegen/codegen",
+		"../fmt",
+	}, ts.Get("build").Metadata().Dependencies)
+	assert.True(t, ts.Has("../shared/build"))
+	assert.True(t, ts.Has("../tools/codegen/codegen"))
+	assert.Equal(t, "testdata/workspace/shared", ts.Get("../shared/build").(dirGetter).Dir())
+
+	// The root's reference back into app resolves to the same task.
+	assert.Equal(t, []string{"build"}, ts.Get("../all").Metadata().Dependencies)
+}
+
+func TestLoadOutsideWorkspace(t *testing.T) {
+	_, err := Load("./testdata/workspace/escape")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'build' lists dependency '../../very-nested/test', which is outside of the workspace.\n- Task 'build' lists dependency '../../very-nested/test', which is not the ID of a task.")
+}
+
+func TestLoadMatrix(t *testing.T) {
+	ts, err := Load("./testdata/matrix")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"test",
+		"test[os=linux,service=api]",
+		"test[os=linux,service=web]",
+		"test[os=darwin,service=api]",
+		"test[os=darwin,service=web]",
+		"build-api",
+		"build-web",
+		"deploy",
+	}, ts.IDs())
+
+	group := ts.Get("test").Metadata()
+	assert.Equal(t, "Test each service on each OS.", group.Description)
+	assert.Equal(t, map[string][]string{
+		"service": {"api", "web"},
+		"os":      {"linux", "darwin"},
+	}, group.Matrix)
+	assert.Equal(t, ts.IDs()[1:5], group.Dependencies)
+
+	expansion := ts.Get("test[os=darwin,service=web]")
+	assert.Nil(t, expansion.Metadata().Matrix)
+	assert.Equal(t, []string{"build-web"}, expansion.Metadata().Dependencies)
+
+	var out strings.Builder
+	err = expansion.Start(context.Background(), make(chan struct{}), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "web override web\n", out.String())
+}
+
+func TestLoadBadMatrix(t *testing.T) {
+	_, err := Load("./testdata/bad-matrix")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'test' has no values for matrix key 'os'.\n- Task 'test' has invalid value 'web/admin' for matrix key 'service'.")
+}
+
+func TestLoadSources(t *testing.T) {
+	ts, err := Load("./testdata/sources")
+	assert.NoError(t, err)
+	meta := ts.Get("web/css").Metadata()
+	assert.Equal(t, []string{"web/css/**/*.css", "web/postcss.config.js"}, meta.Sources)
+	assert.Equal(t, []string{"web/dist/style.css"}, meta.Outputs)
+
+	_, err = Load("./testdata/long-sources")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'dev' has sources, but only short tasks can have sources.")
+}
+
+func TestLoadStatus(t *testing.T) {
+	ts, err := Load("./testdata/status")
+	assert.NoError(t, err)
+	assert.Equal(t, "docker image inspect app:dev >/dev/null 2>&1", ts.Get("image").Metadata().Status)
+
+	_, err = Load("./testdata/long-status")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'db' has a status command, but only short tasks can have status commands.")
+}
+
+func TestLoadHooks(t *testing.T) {
+	ts, err := Load("./testdata/hooks")
+	assert.NoError(t, err)
+
+	meta := ts.Get("test").Metadata()
+	assert.Equal(t, []string{"db/create"}, meta.Before)
+	assert.Equal(t, []string{"report"}, meta.After)
+	assert.Equal(t, []string{"db/drop"}, meta.Finally)
+	assert.True(t, ts.Has("db/drop"))
+
+	settings, err := LoadSettings("./testdata/hooks")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db/drop", "stop-containers"}, settings.OnExit)
+
+	_, err = Load("./testdata/bad-hooks")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'test' lists before hook 'seed', which has dependencies. Hooks run without their dependencies, so they can't have any.\n- Task 'test' lists finally hook 'drop', which has hooks. Hooks run without their own hooks, so they can't have any.")
+}
+
+func TestLoadSettings(t *testing.T) {
+	settings, err := LoadSettings("./testdata/resources")
+	assert.NoError(t, err)
+	assert.Equal(t, Settings{
+		Concurrency: 4,
+		Resources:   map[string]int{"db": 1, "port-8080": 2},
+	}, settings)
+
+	ts, err := Load("./testdata/resources")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db"}, ts.Get("test").Metadata().Resources)
+	assert.Equal(t, []string{"db", "port-8080"}, ts.Get("e2e[browser=firefox]").Metadata().Resources)
+
+	settings, err = LoadSettings("./testdata/vars")
+	assert.NoError(t, err)
+	assert.Equal(t, Settings{}, settings)
+
+	_, err = LoadSettings("./testdata/bad-resources")
+	assert.EqualError(t, err, "invalid taskfile\n- The taskfile has a negative concurrency.\n- Resource 'db' has a limit of 0, but limits must be at least 1.")
+
+	_, err = Load("./testdata/bad-resources")
+	assert.EqualError(t, err, "invalid taskfile\n- Task 'server' uses resources, but only short tasks can use resources.")
+}
@@ -0,0 +1,120 @@
+package taskfile
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// interpolate replaces each "${NAME}" in s with the value of the variable
+// NAME. "$${" is an escaped, literal "${".
+//
+// References to variables that aren't defined are left as they are, and
+// their names are returned in undefined. So are references that aren't
+// simple variable names, like bash's "${1:-default}".
+func interpolate(s string, vars map[string]string) (out string, undefined []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 || !isVarName(s[i+2:i+end]) {
+			b.WriteString("${")
+			i += 2
+			continue
+		}
+		name := s[i+2 : i+end]
+		if v, ok := vars[name]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[i : i+end+1])
+			undefined = append(undefined, name)
+		}
+		i += end + 1
+	}
+	return b.String(), undefined
+}
+
+func isVarName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// interpolate expands variable references in t's cmd, status, env,
+// env_file, watch, watch_ignore, sources, outputs, dir, dependencies, and
+// triggers.
+// Variables set from the command line take precedence over t's own vars,
+// which take precedence over the taskfile's. If t was expanded from a
+// matrix, its matrix values take precedence over all of them.
+//
+// It returns a problem for each reference to an undefined variable, except
+// in cmd and status, where such references are left for bash to expand.
+func (t taskfileTask) interpolate(fileVars, setVars map[string]string) (taskfileTask, []string) {
+	vars := map[string]string{}
+	maps.Copy(vars, fileVars)
+	maps.Copy(vars, t.Vars)
+	maps.Copy(vars, setVars)
+	maps.Copy(vars, t.matrix)
+
+	var problems []string
+	expand := func(field, s string) (string, bool) {
+		out, undefined := interpolate(s, vars)
+		for _, name := range undefined {
+			problems = append(problems, fmt.Sprintf("Task '%s' uses undefined variable '%s' in %s.", t.ID, name, field))
+		}
+		return out, len(undefined) == 0
+	}
+	expandAll := func(field string, ss []string) []string {
+		var out []string
+		for _, s := range ss {
+			if s, ok := expand(field, s); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+
+	t.CMD, _ = interpolate(t.CMD, vars)
+	t.Status, _ = interpolate(t.Status, vars)
+	t.WorkDir, _ = expand("dir", t.WorkDir)
+	t.Watch = expandAll("watch", t.Watch)
+	t.WatchIgnore = expandAll("watch_ignore", t.WatchIgnore)
+	t.Sources = expandAll("sources", t.Sources)
+	t.Outputs = expandAll("outputs", t.Outputs)
+	t.EnvFile = expandAll("env_file", t.EnvFile)
+	t.Dependencies = expandAll("dependencies", t.Dependencies)
+	t.Triggers = expandAll("triggers", t.Triggers)
+	t.Before = expandAll("before", t.Before)
+	t.After = expandAll("after", t.After)
+	t.Finally = expandAll("finally", t.Finally)
+	t.Resources = expandAll("resources", t.Resources)
+	if t.Env != nil {
+		env := make(map[string]string, len(t.Env))
+		for k, v := range t.Env {
+			env[k], _ = expand("env", v)
+		}
+		t.Env = env
+	}
+	return t, problems
+}
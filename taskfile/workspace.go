@@ -0,0 +1,59 @@
+package taskfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Find returns the nearest directory, starting at dir and walking up toward
+// the filesystem root, that contains a tasks.toml. If dir is relative, so is
+// the result, as in "../..".
+func Find(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for candidate := dir; ; candidate = filepath.Join(candidate, "..") {
+		if _, err := os.Stat(filepath.Join(candidate, "tasks.toml")); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if parent := filepath.Dir(abs); parent != abs {
+			abs = parent
+		} else {
+			return "", fmt.Errorf("no tasks.toml found in %s or any parent directory", dir)
+		}
+	}
+}
+
+// workspaceRoot returns the root of the workspace that the taskfile in cwd
+// belongs to, relative to cwd. That's the nearest directory, starting at cwd
+// and walking up, whose tasks.toml sets "workspace = true". If there isn't
+// one, the taskfile in cwd is its own workspace, and the root is ".".
+func workspaceRoot(cwd string) (string, error) {
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		return "", err
+	}
+	for rel := "."; ; rel = filepath.Join(rel, "..") {
+		// Problems with the taskfiles themselves are reported when
+		// they're loaded; here, an unreadable taskfile just isn't a
+		// workspace root.
+		var marker struct {
+			Workspace bool `toml:"workspace"`
+		}
+		if _, err := toml.DecodeFile(filepath.Join(abs, "tasks.toml"), &marker); err == nil && marker.Workspace {
+			return rel, nil
+		}
+		if parent := filepath.Dir(abs); parent != abs {
+			abs = parent
+		} else {
+			return ".", nil
+		}
+	}
+}
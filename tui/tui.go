@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"monks.co/run/internal/mutex"
@@ -27,17 +28,19 @@ import (
 // together, and blocks until the user quits or the context is canceled.
 //
 // The run uses [runner.RunTypeLong], so it keeps running and restarts
-// failed tasks until the user exits the TUI.
-func Start(ctx context.Context, stdin io.Reader, stdout io.Writer, dir string, allTasks task.Library, taskID string) error {
+// failed tasks until the user exits the TUI. Any opts are passed along to
+// [runner.New]. The run's session is named after its root tasks, joined with
+// "+", as in "api+web".
+func Start(ctx context.Context, stdin io.Reader, stdout io.Writer, dir string, allTasks task.Library, taskIDs []string, opts ...runner.Option) error {
 	zone.NewGlobal()
 
 	t := &tui{
 		mu:          mutex.New("tui"),
-		sessionName: taskID,
+		sessionName: strings.Join(taskIDs, "+"),
 		dir:         dir,
 	}
 
-	r, err := runner.New(runner.RunTypeLong, dir, allTasks, taskID, t)
+	r, err := runner.New(runner.RunTypeLong, dir, allTasks, taskIDs, t, opts...)
 	if err != nil {
 		return err
 	}
@@ -67,7 +70,7 @@ func Start(ctx context.Context, stdin io.Reader, stdout io.Writer, dir string, a
 
 	// Create session for programmatic access.
 	absDir, _ := filepath.Abs(dir)
-	sess, sessErr := session.New(taskID, absDir, r, program.Send)
+	sess, sessErr := session.New(t.sessionName, absDir, r, program.Send)
 	if sessErr != nil {
 		// Non-fatal: log the error but continue without session.
 		// This can happen if another instance is running.
@@ -90,9 +93,13 @@ func Start(ctx context.Context, stdin io.Reader, stdout io.Writer, dir string, a
 	_, programErr := program.Run()
 
 	// Program exited (user quit). Clean up session and cancel the runner.
+	// The run's cleanup, like its tasks' finally hooks and its on-exit
+	// tasks, happens after the program has exited, so print its output
+	// instead.
 	if sess != nil {
 		sess.Close()
 	}
+	t.exit(printer.New(gutterWidth, stdout, true))
 	runCancel()
 
 	// Wait for the runner to finish.
@@ -114,6 +121,9 @@ type tui struct {
 
 	interleaved runner.MultiWriter
 
+	// nil until the program exits; then, output goes here instead.
+	exited runner.MultiWriter
+
 	// Session context for log file paths.
 	sessionName string
 	dir         string
@@ -134,6 +144,7 @@ func (a *tui) Writer(id string) io.Writer {
 	}
 
 	return tuiWriter{
+		tui:               a,
 		mu:                mutex.New("writer:" + id),
 		id:                id,
 		interleavedWriter: a.interleaved,
@@ -141,10 +152,24 @@ func (a *tui) Writer(id string) io.Writer {
 	}
 }
 
+// exit sends the output written after the program has exited to w.
+func (a *tui) exit(w runner.MultiWriter) {
+	defer a.mu.Lock("exit").Unlock()
+	a.exited = w
+}
+
+// exitedWriter returns where output goes once the program has exited, or nil
+// if it hasn't.
+func (a *tui) exitedWriter() runner.MultiWriter {
+	defer a.mu.Lock("exitedWriter").Unlock()
+	return a.exited
+}
+
 // *writer implements io.Writer
 var _ io.Writer = tuiWriter{}
 
 type tuiWriter struct {
+	tui               *tui
 	mu                *mutex.Mutex
 	id                string
 	interleavedWriter runner.MultiWriter
@@ -157,6 +182,13 @@ func (w tuiWriter) Write(bs []byte) (int, error) {
 	if w.send == nil {
 		panic("nil send")
 	}
+	if exited := w.tui.exitedWriter(); exited != nil {
+		// The interleaved stream only repeats the other streams.
+		if w.id != runner.InternalTaskInterleaved {
+			exited.Writer(w.id).Write(bs)
+		}
+		return len(bs), nil
+	}
 	if w.id != runner.InternalTaskInterleaved {
 		if w.interleavedWriter == nil {
 			panic("nil interleaved writer")
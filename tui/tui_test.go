@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"monks.co/run/internal/mutex"
+	"monks.co/run/printer"
+)
+
+func TestWriterAfterExit(t *testing.T) {
+	// Output written once the program has exited, like the run's
+	// cleanup, goes to stdout instead of the program.
+	var sent []tea.Msg
+	a := &tui{mu: mutex.New("tui")}
+	w := tuiWriter{
+		tui:               a,
+		mu:                mutex.New("writer"),
+		id:                "api",
+		interleavedWriter: printer.New(len("api"), io.Discard, false),
+		send:              func(msg tea.Msg) { sent = append(sent, msg) },
+	}
+	w.Write([]byte("before\n"))
+
+	var stdout strings.Builder
+	a.exit(printer.New(len("api"), &stdout, false))
+	w.Write([]byte("after\n"))
+
+	if len(sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(sent))
+	}
+	if out := stdout.String(); !strings.Contains(out, "after") || strings.Contains(out, "before") {
+		t.Errorf("stdout: got %q, want only the output written after exit", out)
+	}
+}
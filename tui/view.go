@@ -188,10 +188,18 @@ func (m *tuiModel) renderSpinner(id string) string {
 		} else {
 			return m.shortSpinner.View()
 		}
-	case runner.TaskStatusRestarting:
+	case runner.TaskStatusRestarting, runner.TaskStatusStarting:
 		return m.shortSpinner.View()
-	case runner.TaskStatusFailed, runner.TaskStatusCanceled:
+	case runner.TaskStatusQueued:
+		return "…"
+	case runner.TaskStatusBlocked:
+		return "⊘"
+	case runner.TaskStatusUnhealthy:
+		return "!"
+	case runner.TaskStatusFailed, runner.TaskStatusCanceled, runner.TaskStatusCrashLooping:
 		return "×"
+	case runner.TaskStatusTimedOut:
+		return "⧗"
 	case runner.TaskStatusDone:
 		return "✓"
 	default: